@@ -0,0 +1,53 @@
+package bitcask_go
+
+import "time"
+
+// autoMerge 後台定期檢查無效數據的比例，達到閾值時自動進行 merge
+func (db *DB) autoMerge() {
+	defer close(db.mergeDone)
+	ticker := time.NewTicker(db.options.MergeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-db.mergeStop:
+			return
+		case <-ticker.C:
+			if !db.reachMergeRatio() {
+				continue
+			}
+			// 磁盤空間不足等原因導致失敗時，等待下一次檢查再重試
+			_ = db.Merge()
+		}
+	}
+}
+
+// reachMergeRatio 尚未 merge 的無效數據佔數據文件總大小的比例是否達到閾值
+// merge 的結果在下次打開數據庫時才會生效，已經被 merge 處理的無效數據不再計算在內
+func (db *DB) reachMergeRatio() bool {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.closed || db.isMerging {
+		return false
+	}
+	totalSize, err := db.dataFilesSize()
+	if err != nil {
+		return false
+	}
+	reclaimSize := db.reclaimSize - db.mergedSize
+	totalSize -= db.mergedSize
+	if reclaimSize <= 0 || totalSize <= 0 {
+		return false
+	}
+	return float32(reclaimSize)/float32(totalSize) >= db.options.MergeRatio
+}
+
+// stopAutoMerge 通知後台 merge 任務退出，並等待正在進行的 merge 完成
+func (db *DB) stopAutoMerge() {
+	if db.mergeStop == nil {
+		return
+	}
+	db.stopOnce.Do(func() {
+		close(db.mergeStop)
+	})
+	<-db.mergeDone
+}
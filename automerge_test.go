@@ -0,0 +1,92 @@
+package bitcask_go
+
+import (
+	"bitcask-go/data"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDB_AutoMerge(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-auto-merge-1")
+	opts.DirPath = dir
+	opts.MergeInterval = 20 * time.Millisecond
+	opts.MergeRatio = 0.4
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	// 無效數據的比例沒有達到閾值，不會進行 merge
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, db.Put(getTestKey(i), []byte("value")))
+	}
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, db.Put(getTestKey(i), []byte("value")))
+	}
+	time.Sleep(100 * time.Millisecond)
+	mergeFinFileName := filepath.Join(db.getMergePath(), data.MergeFinishedFileName)
+	_, err = os.Stat(mergeFinFileName)
+	assert.True(t, os.IsNotExist(err))
+
+	// 超過閾值之後在後台自動 merge
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, db.Delete(getTestKey(i)))
+	}
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(mergeFinFileName)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Nil(t, db.Put(getTestKey(1000), []byte("value")))
+	assert.Nil(t, db.Close())
+
+	// 重新打開後 merge 的結果生效
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	defer destroyDB(db2)
+	stat, err := db2.Stat()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, stat.KeyNum)
+	assert.Equal(t, int64(0), stat.ReclaimableSize)
+	val, err := db2.Get(getTestKey(1000))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value"), val)
+}
+
+func TestDB_AutoMergeInvalidOptions(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-auto-merge-2")
+	defer os.RemoveAll(dir)
+	opts.DirPath = dir
+
+	opts.MergeRatio = 1.5
+	_, err := Open(opts)
+	assert.NotNil(t, err)
+
+	opts.MergeRatio = 0.5
+	opts.MergeInterval = -time.Second
+	_, err = Open(opts)
+	assert.NotNil(t, err)
+}
+
+func TestDB_MergeNoEnoughSpace(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-auto-merge-3")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	assert.Nil(t, db.Put(getTestKey(1), []byte("value")))
+
+	// 模擬有效數據大小超過磁盤剩餘空間
+	available, err := availableDiskSize(dir)
+	assert.Nil(t, err)
+	db.reclaimSize = -int64(available)
+	assert.Equal(t, ErrNoEnoughSpaceForMerge, db.Merge())
+	_, err = os.Stat(db.getMergePath())
+	assert.True(t, os.IsNotExist(err))
+}
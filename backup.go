@@ -0,0 +1,200 @@
+package bitcask_go
+
+import (
+	"bitcask-go/data"
+	"errors"
+	"fmt"
+	"github.com/gofrs/flock"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Backup 將數據庫在線備份到指定的目錄，備份期間數據庫可以正常讀寫
+// 目標目錄必須不存在或者為空，備份中同時會生成備份清單，可以作為之後增量備份的基礎
+func (db *DB) Backup(destDir string) error {
+	_, err := db.IncrementalBackup(destDir, nil)
+	return err
+}
+
+// IncrementalBackup 增量備份，只拷貝 since 之後新增或者發生變化的文件，since 為空時進行全量備份
+// 數據文件只會追加寫入，因此只需要在加鎖時記錄下活躍文件當前的寫入位置，之後拷貝的就是這一時刻一致的數據
+// 返回的備份清單記錄了組成完整數據庫的所有文件，同時保存在目標目錄中
+func (db *DB) IncrementalBackup(destDir string, since *BackupManifest) (*BackupManifest, error) {
+	if err := prepareEmptyDir(destDir); err != nil {
+		return nil, err
+	}
+
+	// 持久化活躍文件，並記錄需要備份的文件以及活躍文件的大小
+	db.mu.Lock()
+	if db.closed {
+		db.mu.Unlock()
+		return nil, ErrDatabaseClosed
+	}
+	var fileIds []uint32
+	for fid := range db.olderFiles {
+		fileIds = append(fileIds, fid)
+	}
+	var activeFileId uint32
+	var activeFileSize int64 = -1
+	if db.activeFile != nil {
+		if err := db.activeFile.Sync(); err != nil {
+			db.mu.Unlock()
+			return nil, err
+		}
+		activeFileId, activeFileSize = db.activeFile.FileId, db.activeFile.WriteOffset
+		fileIds = append(fileIds, activeFileId)
+	}
+	db.mu.Unlock()
+	sort.Slice(fileIds, func(i, j int) bool { return fileIds[i] < fileIds[j] })
+
+	manifest := newBackupManifest(since)
+	var fileNames []string
+	for _, fid := range fileIds {
+		fileNames = append(fileNames, filepath.Base(data.GetDataFileName(db.options.DirPath, fid)))
+	}
+	// merge 之後生成的 hint 文件和 merge 完成的標識只會在打開數據庫時改變
+	fileNames = append(fileNames, data.HintFileName, data.MergeFinishedFileName)
+
+	for _, fileName := range fileNames {
+		src := filepath.Join(db.options.DirPath, fileName)
+		info, err := os.Stat(src)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		// 舊的數據文件不會再被修改，可以直接拷貝；活躍文件只拷貝記錄下來的部分
+		file := BackupFile{Name: fileName, Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+		if fileName == filepath.Base(data.GetDataFileName(db.options.DirPath, activeFileId)) && activeFileSize >= 0 {
+			file.Size = activeFileSize
+		}
+
+		// 上一次備份之後沒有變化的文件不需要再拷貝
+		if prev, ok := since.file(fileName); ok && prev.Size == file.Size && prev.ModTime == file.ModTime {
+			manifest.Files = append(manifest.Files, prev)
+			continue
+		}
+		checksum, err := copyFile(src, filepath.Join(destDir, fileName), file.Size)
+		if err != nil {
+			return nil, err
+		}
+		file.Checksum, file.BackupId = checksum, manifest.Id
+		manifest.Files = append(manifest.Files, file)
+	}
+
+	if err := manifest.write(destDir); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// Restore 將備份恢復到 dirPath 目錄中，恢復之後可以使用 Open 打開
+// 恢復之前會校驗備份中所有數據文件的記錄，存在損壞時返回 ErrBackupCorrupted
+// 增量備份中缺少之前備份中的文件，單獨恢復時返回 ErrBackupMissing，需要使用 RestoreIncremental
+// dirPath 必須不存在或者為空
+func Restore(backupDir string, dirPath string) error {
+	manifest, err := ReadBackupManifest(backupDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if manifest != nil {
+		for _, file := range manifest.Files {
+			if file.BackupId != manifest.Id {
+				return fmt.Errorf("%w: %s is in backup %d, use RestoreIncremental", ErrBackupMissing, file.Name, file.BackupId)
+			}
+		}
+	}
+
+	ranges, err := Verify(backupDir)
+	if err != nil {
+		return err
+	}
+	if len(ranges) > 0 {
+		return ErrBackupCorrupted
+	}
+	// 有備份清單時按照清單拷貝並校驗每個文件
+	if manifest != nil {
+		return RestoreIncremental(dirPath, backupDir)
+	}
+
+	if err := prepareEmptyDir(dirPath); err != nil {
+		return err
+	}
+	// 恢復期間持有文件鎖，避免數據庫被打開
+	fileLock := flock.New(filepath.Join(dirPath, fileLockName))
+	hold, err := fileLock.TryLock()
+	if err != nil {
+		return err
+	}
+	if !hold {
+		return ErrDatabaseIsUsing
+	}
+	defer func() {
+		_ = fileLock.Unlock()
+	}()
+
+	fileIds, err := ListDataFileIds(backupDir)
+	if err != nil {
+		return err
+	}
+	fileNames := []string{data.HintFileName, data.MergeFinishedFileName}
+	for _, fid := range fileIds {
+		fileNames = append(fileNames, filepath.Base(data.GetDataFileName(backupDir, fid)))
+	}
+	for _, fileName := range fileNames {
+		src := filepath.Join(backupDir, fileName)
+		if _, err := os.Stat(src); os.IsNotExist(err) {
+			continue
+		}
+		if _, err := copyFile(src, filepath.Join(dirPath, fileName), -1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepareEmptyDir 確保目錄存在並且為空
+func prepareEmptyDir(dirPath string) error {
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return os.MkdirAll(dirPath, os.ModePerm)
+		}
+		return err
+	}
+	if len(entries) > 0 {
+		return ErrDirectoryNotEmpty
+	}
+	return nil
+}
+
+// copyFile 拷貝文件的前 size 個字節，size 為負數時拷貝整個文件，返回拷貝內容的 crc 校驗值
+func copyFile(src, dst string, size int64) (uint32, error) {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer dstFile.Close()
+
+	hash := crc32.NewIEEE()
+	writer := io.MultiWriter(dstFile, hash)
+	if size < 0 {
+		_, err = io.Copy(writer, srcFile)
+	} else {
+		_, err = io.CopyN(writer, srcFile, size)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return hash.Sum32(), dstFile.Sync()
+}
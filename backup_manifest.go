@@ -0,0 +1,122 @@
+package bitcask_go
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gofrs/flock"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// BackupManifestFileName 備份清單的文件名
+const BackupManifestFileName = "backup-manifest"
+
+// BackupManifest 備份清單，記錄了備份時組成完整數據庫的所有文件
+// 增量備份中只包含新增或者變化的文件，其他文件仍然保存在之前的備份中，通過 BackupId 找到
+type BackupManifest struct {
+	Id    int64        `json:"id"` // 備份的標識，同一條備份鏈上嚴格遞增
+	Files []BackupFile `json:"files"`
+}
+
+// BackupFile 備份中的一個文件
+type BackupFile struct {
+	Name     string `json:"name"`
+	Size     int64  `json:"size"`
+	ModTime  int64  `json:"mod_time"`  // 備份時源文件的修改時間，用於判斷文件是否發生了變化
+	Checksum uint32 `json:"checksum"`  // 文件內容的 crc 校驗值
+	BackupId int64  `json:"backup_id"` // 文件內容所在的備份
+}
+
+func newBackupManifest(since *BackupManifest) *BackupManifest {
+	id := time.Now().UnixNano()
+	if since != nil && id <= since.Id {
+		id = since.Id + 1
+	}
+	return &BackupManifest{Id: id}
+}
+
+// file 查找清單中的文件，清單為空時返回 false
+func (m *BackupManifest) file(name string) (BackupFile, bool) {
+	if m == nil {
+		return BackupFile{}, false
+	}
+	for _, file := range m.Files {
+		if file.Name == name {
+			return file, true
+		}
+	}
+	return BackupFile{}, false
+}
+
+func (m *BackupManifest) write(dirPath string) error {
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dirPath, BackupManifestFileName), buf, 0644)
+}
+
+// ReadBackupManifest 讀取備份目錄中的備份清單
+func ReadBackupManifest(backupDir string) (*BackupManifest, error) {
+	buf, err := os.ReadFile(filepath.Join(backupDir, BackupManifestFileName))
+	if err != nil {
+		return nil, err
+	}
+	manifest := &BackupManifest{}
+	if err := json.Unmarshal(buf, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// RestoreIncremental 將全量備份以及之後的增量備份合併恢復到 dirPath 目錄中
+// backupDirs 按照備份的先後順序排列，最後一個備份的清單決定了恢復之後的數據
+// 每個文件拷貝時都會校驗 crc，不一致時返回 ErrBackupCorrupted；dirPath 必須不存在或者為空
+func RestoreIncremental(dirPath string, backupDirs ...string) error {
+	if len(backupDirs) == 0 {
+		return errors.New("no backup to restore")
+	}
+	dirsById := make(map[int64]string)
+	var manifest *BackupManifest
+	for _, backupDir := range backupDirs {
+		m, err := ReadBackupManifest(backupDir)
+		if err != nil {
+			return err
+		}
+		dirsById[m.Id] = backupDir
+		manifest = m
+	}
+
+	if err := prepareEmptyDir(dirPath); err != nil {
+		return err
+	}
+	// 恢復期間持有文件鎖，避免數據庫被打開
+	fileLock := flock.New(filepath.Join(dirPath, fileLockName))
+	hold, err := fileLock.TryLock()
+	if err != nil {
+		return err
+	}
+	if !hold {
+		return ErrDatabaseIsUsing
+	}
+	defer func() {
+		_ = fileLock.Unlock()
+	}()
+
+	for _, file := range manifest.Files {
+		backupDir, ok := dirsById[file.BackupId]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrBackupMissing, file.Name)
+		}
+		checksum, err := copyFile(filepath.Join(backupDir, file.Name), filepath.Join(dirPath, file.Name), -1)
+		if err != nil {
+			return err
+		}
+		if checksum != file.Checksum {
+			return ErrBackupCorrupted
+		}
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package bitcask_go
+
+import (
+	"bitcask-go/data"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDB_Backup(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-backup")
+	opts.DirPath = dir
+	opts.DataFileSize = 32 * 1024
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	for i := 0; i < 1000; i++ {
+		err := db.Put(getTestKey(i), []byte("value"))
+		assert.Nil(t, err)
+	}
+
+	backupDir, _ := os.MkdirTemp("", "bitcask-go-backup-dest")
+	defer os.RemoveAll(backupDir)
+	err = db.Backup(backupDir)
+	assert.Nil(t, err)
+
+	// 備份之後的寫入不會出現在備份中
+	for i := 1000; i < 1100; i++ {
+		err := db.Put(getTestKey(i), []byte("value"))
+		assert.Nil(t, err)
+	}
+	err = db.Delete(getTestKey(0))
+	assert.Nil(t, err)
+
+	// 目標目錄不為空時不能備份
+	err = db.Backup(backupDir)
+	assert.Equal(t, ErrDirectoryNotEmpty, err)
+
+	backupOpts := opts
+	backupOpts.DirPath = backupDir
+	backupDB, err := Open(backupOpts)
+	assert.Nil(t, err)
+	for i := 0; i < 1000; i++ {
+		val, err := backupDB.Get(getTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("value"), val)
+	}
+	_, err = backupDB.Get(getTestKey(1000))
+	assert.Equal(t, ErrKeyNotFound, err)
+	assert.Nil(t, backupDB.Close())
+}
+
+func TestRestore(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-restore")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	for i := 0; i < 100; i++ {
+		err := db.Put(getTestKey(i), []byte("value"))
+		assert.Nil(t, err)
+	}
+	backupDir, _ := os.MkdirTemp("", "bitcask-go-restore-backup")
+	defer os.RemoveAll(backupDir)
+	err = db.Backup(backupDir)
+	assert.Nil(t, err)
+
+	restoreDir, _ := os.MkdirTemp("", "bitcask-go-restore-dest")
+	defer os.RemoveAll(restoreDir)
+	err = Restore(backupDir, restoreDir)
+	assert.Nil(t, err)
+
+	restoreOpts := opts
+	restoreOpts.DirPath = restoreDir
+	restoreDB, err := Open(restoreOpts)
+	assert.Nil(t, err)
+	for i := 0; i < 100; i++ {
+		val, err := restoreDB.Get(getTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("value"), val)
+	}
+	assert.Nil(t, restoreDB.Close())
+
+	// 備份損壞時拒絕恢復
+	fileName := data.GetDataFileName(backupDir, 0)
+	buf, err := os.ReadFile(fileName)
+	assert.Nil(t, err)
+	buf[len(buf)/2] ^= 0xff
+	assert.Nil(t, os.WriteFile(fileName, buf, 0644))
+
+	restoreDir2, _ := os.MkdirTemp("", "bitcask-go-restore-dest")
+	defer os.RemoveAll(restoreDir2)
+	err = Restore(backupDir, restoreDir2)
+	assert.Equal(t, ErrBackupCorrupted, err)
+}
+
+func TestDB_BackupAfterMerge(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-backup-merge")
+	opts.DirPath = dir
+	opts.DataFileSize = 32 * 1024
+	db, err := Open(opts)
+	assert.Nil(t, err)
+
+	for i := 0; i < 2000; i++ {
+		err := db.Put(getTestKey(i), []byte("value"))
+		assert.Nil(t, err)
+	}
+	for i := 0; i < 1000; i++ {
+		err := db.Delete(getTestKey(i))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, db.Merge())
+	// 重啟之後 merge 的結果才會生效，生成 hint 文件
+	assert.Nil(t, db.Close())
+	db, err = Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	backupDir, _ := os.MkdirTemp("", "bitcask-go-backup-merge-dest")
+	defer os.RemoveAll(backupDir)
+	err = db.Backup(backupDir)
+	assert.Nil(t, err)
+	_, err = os.Stat(filepath.Join(backupDir, data.HintFileName))
+	assert.Nil(t, err)
+
+	backupOpts := opts
+	backupOpts.DirPath = backupDir
+	backupDB, err := Open(backupOpts)
+	assert.Nil(t, err)
+	defer backupDB.Close()
+	for i := 0; i < 2000; i++ {
+		val, err := backupDB.Get(getTestKey(i))
+		if i < 1000 {
+			assert.Equal(t, ErrKeyNotFound, err)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("value"), val)
+	}
+}
+
+func TestDB_IncrementalBackup(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-incr-backup")
+	opts.DirPath = dir
+	opts.DataFileSize = 32 * 1024
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	for i := 0; i < 1000; i++ {
+		err := db.Put(getTestKey(i), []byte("value-1"))
+		assert.Nil(t, err)
+	}
+	baseDir, _ := os.MkdirTemp("", "bitcask-go-incr-backup-base")
+	defer os.RemoveAll(baseDir)
+	base, err := db.IncrementalBackup(baseDir, nil)
+	assert.Nil(t, err)
+
+	for i := 500; i < 1500; i++ {
+		err := db.Put(getTestKey(i), []byte("value-2"))
+		assert.Nil(t, err)
+	}
+	incrDir, _ := os.MkdirTemp("", "bitcask-go-incr-backup-incr")
+	defer os.RemoveAll(incrDir)
+	incr, err := db.IncrementalBackup(incrDir, base)
+	assert.Nil(t, err)
+	assert.Greater(t, incr.Id, base.Id)
+
+	// 沒有變化的舊數據文件不會被重複拷貝
+	manifest, err := ReadBackupManifest(incrDir)
+	assert.Nil(t, err)
+	assert.Equal(t, incr, manifest)
+	copied := 0
+	for _, file := range manifest.Files {
+		if file.BackupId == incr.Id {
+			copied++
+			_, err := os.Stat(filepath.Join(incrDir, file.Name))
+			assert.Nil(t, err)
+		} else {
+			assert.Equal(t, base.Id, file.BackupId)
+			_, err := os.Stat(filepath.Join(incrDir, file.Name))
+			assert.True(t, os.IsNotExist(err))
+		}
+	}
+	assert.Less(t, copied, len(manifest.Files))
+
+	// 只有增量備份無法恢復
+	restoreDir, _ := os.MkdirTemp("", "bitcask-go-incr-restore")
+	defer os.RemoveAll(restoreDir)
+	err = RestoreIncremental(restoreDir, incrDir)
+	assert.ErrorIs(t, err, ErrBackupMissing)
+	restoreDir3, _ := os.MkdirTemp("", "bitcask-go-incr-restore")
+	defer os.RemoveAll(restoreDir3)
+	err = Restore(incrDir, restoreDir3)
+	assert.ErrorIs(t, err, ErrBackupMissing)
+	entries, err := os.ReadDir(restoreDir3)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(entries))
+
+	restoreDir2, _ := os.MkdirTemp("", "bitcask-go-incr-restore")
+	defer os.RemoveAll(restoreDir2)
+	err = RestoreIncremental(restoreDir2, baseDir, incrDir)
+	assert.Nil(t, err)
+
+	restoreOpts := opts
+	restoreOpts.DirPath = restoreDir2
+	restoreDB, err := Open(restoreOpts)
+	assert.Nil(t, err)
+	defer restoreDB.Close()
+	for i := 0; i < 1500; i++ {
+		val, err := restoreDB.Get(getTestKey(i))
+		assert.Nil(t, err)
+		if i < 500 {
+			assert.Equal(t, []byte("value-1"), val)
+		} else {
+			assert.Equal(t, []byte("value-2"), val)
+		}
+	}
+}
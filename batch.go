@@ -0,0 +1,185 @@
+package bitcask_go
+
+import (
+	"bitcask-go/data"
+	"encoding/binary"
+	"sync"
+)
+
+var txnFinKey = []byte("txn-fin")
+
+// WriteBatch 原子批量寫數據，保證原子性
+type WriteBatch struct {
+	options       WriteBatchOptions
+	mu            *sync.Mutex
+	db            *DB
+	pendingWrites map[string]*data.LogRecord // 暫存用戶寫入的數據
+}
+
+// NewWriteBatch 初始化 WriteBatch
+func (db *DB) NewWriteBatch(opts WriteBatchOptions) *WriteBatch {
+	return &WriteBatch{
+		options:       opts,
+		mu:            new(sync.Mutex),
+		db:            db,
+		pendingWrites: make(map[string]*data.LogRecord),
+	}
+}
+
+// Put 批量寫數據
+func (wb *WriteBatch) Put(key []byte, value []byte) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	// 暫存 LogRecord
+	logRecord := &data.LogRecord{Key: key, Value: value}
+	wb.pendingWrites[string(key)] = logRecord
+	return nil
+}
+
+// Delete 刪除數據
+func (wb *WriteBatch) Delete(key []byte) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	wb.db.mu.RLock()
+	if wb.db.closed {
+		wb.db.mu.RUnlock()
+		return ErrDatabaseClosed
+	}
+	// 數據不存在則直接返回
+	logRecordPos := wb.db.index.Get(key)
+	wb.db.mu.RUnlock()
+	if logRecordPos == nil {
+		if wb.pendingWrites[string(key)] != nil {
+			delete(wb.pendingWrites, string(key))
+		}
+		return nil
+	}
+
+	// 暫存 LogRecord
+	logRecord := &data.LogRecord{Key: key, Type: data.LogRecordDeleted}
+	wb.pendingWrites[string(key)] = logRecord
+	return nil
+}
+
+// Commit 提交事務，將暫存的數據全部寫到數據文件，並更新內存索引
+func (wb *WriteBatch) Commit() error {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	if len(wb.pendingWrites) == 0 {
+		return nil
+	}
+	if uint(len(wb.pendingWrites)) > wb.options.MaxBatchNum {
+		return ErrExceedMaxBatchNum
+	}
+
+	// 加鎖保證事務提交串行化
+	wb.db.mu.Lock()
+	defer wb.db.mu.Unlock()
+	if wb.db.closed {
+		return ErrDatabaseClosed
+	}
+
+	if err := wb.db.commitWrites(wb.pendingWrites, wb.options.SyncWrites); err != nil {
+		return err
+	}
+
+	// 清空暫存數據
+	wb.pendingWrites = make(map[string]*data.LogRecord)
+
+	return nil
+}
+
+// commitWrites 以事務的方式寫入一組數據，並更新內存索引
+// 在訪問此方法前必須持有互斥鎖
+func (db *DB) commitWrites(pendingWrites map[string]*data.LogRecord, syncWrites bool) error {
+	// 獲取當前最新的序列號，批次中的所有數據共用同一個序列號
+	db.seqNo++
+	seqNo := db.seqNo
+
+	// 開始寫數據到數據文件當中
+	positions := make(map[string]*data.LogRecordPos)
+	for _, record := range pendingWrites {
+		logRecordPos, err := db.appendLogRecord(&data.LogRecord{
+			Key:   logRecordKeyWithSeq(record.Key, seqNo, true),
+			Value: record.Value,
+			Type:  record.Type,
+		})
+		if err != nil {
+			return err
+		}
+		positions[string(record.Key)] = logRecordPos
+	}
+
+	// 寫一條標識事務完成的數據
+	finishedRecord := &data.LogRecord{
+		Key:  logRecordKeyWithSeq(txnFinKey, seqNo, true),
+		Type: data.LogRecordTxnFinished,
+	}
+	finishedPos, err := db.appendLogRecord(finishedRecord)
+	if err != nil {
+		return err
+	}
+	db.reclaimSize += int64(finishedPos.Size)
+
+	// 根據配置決定是否持久化
+	if syncWrites && db.activeFile != nil {
+		if err := db.activeFile.Sync(); err != nil {
+			return err
+		}
+	}
+
+	// 更新內存索引，並統計失效的數據
+	for _, record := range pendingWrites {
+		pos := positions[string(record.Key)]
+		db.saveForSnapshots(record.Key)
+		var oldPos *data.LogRecordPos
+		if record.Type == data.LogRecordNormal {
+			oldPos = db.index.Put(record.Key, pos)
+		}
+		if record.Type == data.LogRecordDeleted {
+			oldPos, _ = db.index.Delete(record.Key)
+			db.reclaimSize += int64(pos.Size)
+		}
+		if oldPos != nil {
+			db.reclaimSize += int64(oldPos.Size)
+		}
+	}
+	return nil
+}
+
+// logRecordKeyWithSeq key+Seq Number 編碼
+// 序列號左移一位後存儲，最低位標識該記錄是否屬於批量寫入
+// 批量寫入的記錄只有讀到對應的事務完成標識後才會生效
+func logRecordKeyWithSeq(key []byte, seqNo uint64, inTxn bool) []byte {
+	encSeq := seqNo << 1
+	if inTxn {
+		encSeq |= 1
+	}
+	seq := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(seq[:], encSeq)
+
+	encKey := make([]byte, n+len(key))
+	copy(encKey[:n], seq[:n])
+	copy(encKey[n:], key)
+
+	return encKey
+}
+
+// ParseLogRecordKey 解析 LogRecord 的 key，獲取實際的 key、序列號以及是否屬於批量寫入
+// key 的前面是變長編碼的序列號，序列號左移了一位，最低位標識是否屬於批量寫入，無法解析出序列號時原樣返回 key
+func ParseLogRecordKey(key []byte) ([]byte, uint64, bool) {
+	encSeq, n := binary.Uvarint(key)
+	if n <= 0 {
+		return key, 0, false
+	}
+	return key[n:], encSeq >> 1, encSeq&1 == 1
+}
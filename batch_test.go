@@ -0,0 +1,114 @@
+package bitcask_go
+
+import (
+	"bitcask-go/data"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestDB_WriteBatch(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-batch-1")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	// 寫數據之後並不提交
+	wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+	err = wb.Put(getTestKey(1), []byte("value-1"))
+	assert.Nil(t, err)
+	err = wb.Delete(getTestKey(2))
+	assert.Nil(t, err)
+
+	_, err = db.Get(getTestKey(1))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	// 正常提交數據
+	err = wb.Commit()
+	assert.Nil(t, err)
+
+	val, err := db.Get(getTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value-1"), val)
+
+	// 刪除有效的數據
+	wb2 := db.NewWriteBatch(DefaultWriteBatchOptions)
+	err = wb2.Delete(getTestKey(1))
+	assert.Nil(t, err)
+	err = wb2.Commit()
+	assert.Nil(t, err)
+
+	_, err = db.Get(getTestKey(1))
+	assert.Equal(t, ErrKeyNotFound, err)
+}
+
+func TestDB_WriteBatchRestart(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-batch-2")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	err = db.Put(getTestKey(1), []byte("value-1"))
+	assert.Nil(t, err)
+
+	wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+	err = wb.Put(getTestKey(2), []byte("value-2"))
+	assert.Nil(t, err)
+	err = wb.Delete(getTestKey(1))
+	assert.Nil(t, err)
+	err = wb.Commit()
+	assert.Nil(t, err)
+
+	err = wb.Put(getTestKey(11), []byte("value-11"))
+	assert.Nil(t, err)
+	err = wb.Commit()
+	assert.Nil(t, err)
+
+	// 模擬寫了一半崩潰的事務：只有數據，沒有事務完成的標識
+	_, err = db.appendLogRecord(&data.LogRecord{
+		Key:   logRecordKeyWithSeq(getTestKey(3), db.seqNo+1, true),
+		Value: []byte("value-3"),
+	})
+	assert.Nil(t, err)
+
+	// 重啟
+	err = db.Close()
+	assert.Nil(t, err)
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	// 未提交事務的序列號也不能再被使用
+	assert.Equal(t, uint64(4), db2.seqNo)
+
+	_, err = db2.Get(getTestKey(1))
+	assert.Equal(t, ErrKeyNotFound, err)
+	val, err := db2.Get(getTestKey(2))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value-2"), val)
+	_, err = db2.Get(getTestKey(11))
+	assert.Nil(t, err)
+	_, err = db2.Get(getTestKey(3))
+	assert.Equal(t, ErrKeyNotFound, err)
+	err = db2.Close()
+	assert.Nil(t, err)
+}
+
+func TestDB_WriteBatchExceedMaxNum(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-batch-3")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	wb := db.NewWriteBatch(WriteBatchOptions{MaxBatchNum: 2, SyncWrites: false})
+	for i := 0; i < 3; i++ {
+		err := wb.Put(getTestKey(i), []byte("value"))
+		assert.Nil(t, err)
+	}
+	err = wb.Commit()
+	assert.Equal(t, ErrExceedMaxBatchNum, err)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	bitcask "bitcask-go"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"time"
+)
+
+// handler 將 HTTP 請求映射到數據庫的操作
+// value 默認以原始字節傳輸，帶上 encoding=base64 參數時請求和響應中的 value 使用 base64 編碼
+// 同時路徑中的 key 以及 key 列表使用 URL 安全的 base64 編碼，列出的 key 可以直接拼接到路徑中
+type handler struct {
+	db           *bitcask.DB
+	backupRoot   string // 備份只能寫入到這個目錄下，為空時不允許通過接口備份
+	maxValueSize int64  // 寫入的 value 的最大長度，避免過大的請求體耗盡內存
+}
+
+// 默認允許寫入的 value 的最大長度
+const defaultMaxValueSize = 64 << 20
+
+func newHandler(db *bitcask.DB, backupRoot string, maxValueSize int64) http.Handler {
+	h := &handler{db: db, backupRoot: backupRoot, maxValueSize: maxValueSize}
+	mux := http.NewServeMux()
+	// key 中可以包含 /
+	mux.HandleFunc("PUT /keys/{key...}", h.put)
+	mux.HandleFunc("GET /keys/{key...}", h.get)
+	mux.HandleFunc("DELETE /keys/{key...}", h.delete)
+	mux.HandleFunc("GET /keys", h.listKeys)
+	mux.HandleFunc("GET /stat", h.stat)
+	mux.HandleFunc("POST /merge", h.merge)
+	mux.HandleFunc("POST /backup", h.backup)
+	return mux
+}
+
+// put 寫入數據，可以通過 ttl 參數指定過期時間，例如 ttl=10s
+func (h *handler) put(w http.ResponseWriter, r *http.Request) {
+	key, err := pathKey(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	// 限制的是解碼之後的 value 的長度，base64 模式下先按編碼之後的長度限制請求體，解碼之後再檢查一次
+	limit := h.maxValueSize
+	if isBase64(r) {
+		limit = int64(base64.StdEncoding.EncodedLen(int(limit)))
+	}
+	value, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeError(w, http.StatusRequestEntityTooLarge, err)
+			return
+		}
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if isBase64(r) {
+		if value, err = base64.StdEncoding.DecodeString(string(value)); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if int64(len(value)) > h.maxValueSize {
+			writeError(w, http.StatusRequestEntityTooLarge, errors.New("the value exceeds the max value size"))
+			return
+		}
+	}
+
+	if ttl := r.URL.Query().Get("ttl"); ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		err = h.db.PutWithTTL(key, value, d)
+	} else {
+		err = h.db.Put(key, value)
+	}
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) get(w http.ResponseWriter, r *http.Request) {
+	key, err := pathKey(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	value, err := h.db.Get(key)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	if isBase64(r) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = io.WriteString(w, base64.StdEncoding.EncodeToString(value))
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, _ = w.Write(value)
+}
+
+func (h *handler) delete(w http.ResponseWriter, r *http.Request) {
+	key, err := pathKey(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := h.db.Delete(key); err != nil {
+		writeDBError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listKeys 返回所有帶有 prefix 前綴的 key，base64 模式下 prefix 與路徑中的 key 使用相同的編碼
+func (h *handler) listKeys(w http.ResponseWriter, r *http.Request) {
+	prefix := []byte(r.URL.Query().Get("prefix"))
+	if isBase64(r) {
+		var err error
+		if prefix, err = base64.URLEncoding.DecodeString(string(prefix)); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	iterOpts := bitcask.DefaultIteratorOptions
+	iterOpts.Prefix = prefix
+	iter := h.db.NewIterator(iterOpts)
+	keys := make([]string, 0)
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		if isBase64(r) {
+			keys = append(keys, base64.URLEncoding.EncodeToString(iter.Key()))
+		} else {
+			keys = append(keys, string(iter.Key()))
+		}
+	}
+	iter.Close()
+	writeJSON(w, http.StatusOK, keys)
+}
+
+func (h *handler) stat(w http.ResponseWriter, r *http.Request) {
+	stat, err := h.db.Stat()
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stat)
+}
+
+func (h *handler) merge(w http.ResponseWriter, r *http.Request) {
+	if err := h.db.Merge(); err != nil {
+		writeDBError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// backup 在線備份到備份根目錄下 dir 參數指定的子目錄
+func (h *handler) backup(w http.ResponseWriter, r *http.Request) {
+	if h.backupRoot == "" {
+		writeError(w, http.StatusForbidden, errors.New("backup is disabled, the backup root directory is not configured"))
+		return
+	}
+	dir := r.URL.Query().Get("dir")
+	if dir == "" {
+		writeError(w, http.StatusBadRequest, errors.New("the backup directory is empty"))
+		return
+	}
+	// 不允許絕對路徑以及通過 .. 跳出備份根目錄
+	if !filepath.IsLocal(dir) {
+		writeError(w, http.StatusBadRequest, errors.New("the backup directory must be a relative path inside the backup root"))
+		return
+	}
+	if err := h.db.Backup(filepath.Join(h.backupRoot, dir)); err != nil {
+		writeDBError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func isBase64(r *http.Request) bool {
+	return r.URL.Query().Get("encoding") == "base64"
+}
+
+// pathKey 取出路徑中的 key，base64 模式下需要先解碼
+func pathKey(r *http.Request) ([]byte, error) {
+	key := r.PathValue("key")
+	if isBase64(r) {
+		return base64.URLEncoding.DecodeString(key)
+	}
+	return []byte(key), nil
+}
+
+// writeDBError 根據數據庫返回的錯誤設置對應的狀態碼
+func writeDBError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, bitcask.ErrKeyNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, bitcask.ErrKeyIsEmpty), errors.Is(err, bitcask.ErrKeyTooLarge), errors.Is(err, bitcask.ErrInvalidTTL):
+		status = http.StatusBadRequest
+	case errors.Is(err, bitcask.ErrMergeIsProgress), errors.Is(err, bitcask.ErrDirectoryNotEmpty):
+		status = http.StatusConflict
+	case errors.Is(err, bitcask.ErrDatabaseClosed):
+		status = http.StatusServiceUnavailable
+	case errors.Is(err, bitcask.ErrNoEnoughSpaceForMerge):
+		status = http.StatusInsufficientStorage
+	}
+	writeError(w, status, err)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	bitcask "bitcask-go"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startServer 啟動測試服務，返回服務地址以及清理函數，backupRoot 為空時不允許備份
+func startServer(t *testing.T, backupRoot string) (string, func()) {
+	opts := bitcask.DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-http")
+	opts.DirPath = dir
+	db, err := bitcask.Open(opts)
+	assert.Nil(t, err)
+
+	svr := httptest.NewServer(newHandler(db, backupRoot, defaultMaxValueSize))
+	return svr.URL, func() {
+		svr.Close()
+		_ = db.Close()
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func doRequest(t *testing.T, method, url, body string) (int, string) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	assert.Nil(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	return resp.StatusCode, string(data)
+}
+
+func TestHandler_Keys(t *testing.T) {
+	url, cleanup := startServer(t, "")
+	defer cleanup()
+
+	status, _ := doRequest(t, http.MethodGet, url+"/keys/name", "")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, _ = doRequest(t, http.MethodPut, url+"/keys/name", "bitcask")
+	assert.Equal(t, http.StatusNoContent, status)
+	status, body := doRequest(t, http.MethodGet, url+"/keys/name", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "bitcask", body)
+
+	// key 中可以包含 /
+	status, _ = doRequest(t, http.MethodPut, url+"/keys/user/1", "alice")
+	assert.Equal(t, http.StatusNoContent, status)
+	status, body = doRequest(t, http.MethodGet, url+"/keys/user/1", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "alice", body)
+
+	status, _ = doRequest(t, http.MethodPut, url+"/keys/", "value")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = doRequest(t, http.MethodDelete, url+"/keys/name", "")
+	assert.Equal(t, http.StatusNoContent, status)
+	status, _ = doRequest(t, http.MethodGet, url+"/keys/name", "")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, _ = doRequest(t, http.MethodPut, url+"/keys/temp?ttl=abc", "value")
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestHandler_Base64(t *testing.T) {
+	url, cleanup := startServer(t, "")
+	defer cleanup()
+
+	value := []byte{0x00, 0xff, 0x10, 0x80}
+	encoded := base64.StdEncoding.EncodeToString(value)
+	// base64 模式下路徑中的 key 也需要編碼
+	binPath := "/keys/" + base64.URLEncoding.EncodeToString([]byte("bin"))
+	status, _ := doRequest(t, http.MethodPut, url+binPath+"?encoding=base64", encoded)
+	assert.Equal(t, http.StatusNoContent, status)
+
+	status, body := doRequest(t, http.MethodGet, url+"/keys/bin", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, value, []byte(body))
+	status, body = doRequest(t, http.MethodGet, url+binPath+"?encoding=base64", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, encoded, body)
+
+	status, _ = doRequest(t, http.MethodPut, url+binPath+"?encoding=base64", "not base64!")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// base64 模式下路徑中的 key 使用 URL 安全的 base64 編碼，列出的二進制 key 可以直接用於讀取
+	binKey := []byte{0xfe, 0x00, '/', 0x01, 0xfb, 0xff}
+	encodedKey := base64.URLEncoding.EncodeToString(binKey)
+	status, _ = doRequest(t, http.MethodPut, url+"/keys/"+encodedKey+"?encoding=base64", encoded)
+	assert.Equal(t, http.StatusNoContent, status)
+	status, body = doRequest(t, http.MethodGet, url+"/keys?encoding=base64", "")
+	assert.Equal(t, http.StatusOK, status)
+	var keys []string
+	assert.Nil(t, json.Unmarshal([]byte(body), &keys))
+	assert.Contains(t, keys, encodedKey)
+	for _, key := range keys {
+		status, _ = doRequest(t, http.MethodGet, url+"/keys/"+key+"?encoding=base64", "")
+		assert.Equal(t, http.StatusOK, status)
+	}
+	// base64 模式下 prefix 也需要編碼
+	prefix := neturl.QueryEscape(base64.URLEncoding.EncodeToString(binKey[:2]))
+	status, body = doRequest(t, http.MethodGet, url+"/keys?encoding=base64&prefix="+prefix, "")
+	assert.Equal(t, http.StatusOK, status)
+	keys = nil
+	assert.Nil(t, json.Unmarshal([]byte(body), &keys))
+	assert.Equal(t, []string{encodedKey}, keys)
+	status, _ = doRequest(t, http.MethodGet, url+"/keys?encoding=base64&prefix=not-base64!", "")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = doRequest(t, http.MethodDelete, url+"/keys/"+encodedKey+"?encoding=base64", "")
+	assert.Equal(t, http.StatusNoContent, status)
+	status, _ = doRequest(t, http.MethodGet, url+"/keys/"+encodedKey+"?encoding=base64", "")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, _ = doRequest(t, http.MethodGet, url+"/keys/not-base64!?encoding=base64", "")
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestHandler_MaxValueSize(t *testing.T) {
+	opts := bitcask.DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-http-max-value")
+	opts.DirPath = dir
+	db, err := bitcask.Open(opts)
+	assert.Nil(t, err)
+	svr := httptest.NewServer(newHandler(db, "", 8))
+	defer func() {
+		svr.Close()
+		_ = db.Close()
+		_ = os.RemoveAll(dir)
+	}()
+
+	status, _ := doRequest(t, http.MethodPut, svr.URL+"/keys/a", "12345678")
+	assert.Equal(t, http.StatusNoContent, status)
+	status, _ = doRequest(t, http.MethodPut, svr.URL+"/keys/a", "123456789")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, status)
+
+	// 限制的是解碼之後的長度
+	encoded := base64.StdEncoding.EncodeToString([]byte("12345678"))
+	status, _ = doRequest(t, http.MethodPut, svr.URL+"/keys/YQ==?encoding=base64", encoded)
+	assert.Equal(t, http.StatusNoContent, status)
+	encoded = base64.StdEncoding.EncodeToString([]byte("123456789"))
+	status, _ = doRequest(t, http.MethodPut, svr.URL+"/keys/YQ==?encoding=base64", encoded)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, status)
+
+	status, body := doRequest(t, http.MethodGet, svr.URL+"/keys/a", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "12345678", body)
+}
+
+func TestHandler_ListKeysAndMerge(t *testing.T) {
+	url, cleanup := startServer(t, "")
+	defer cleanup()
+
+	for _, key := range []string{"a1", "a2", "b1"} {
+		status, _ := doRequest(t, http.MethodPut, url+"/keys/"+key, "value")
+		assert.Equal(t, http.StatusNoContent, status)
+	}
+
+	// 過期的 key 不會被列出
+	status, _ := doRequest(t, http.MethodPut, url+"/keys/a3?ttl=50ms", "value")
+	assert.Equal(t, http.StatusNoContent, status)
+	time.Sleep(100 * time.Millisecond)
+
+	status, body := doRequest(t, http.MethodGet, url+"/keys?prefix=a", "")
+	assert.Equal(t, http.StatusOK, status)
+	var keys []string
+	assert.Nil(t, json.Unmarshal([]byte(body), &keys))
+	assert.Equal(t, []string{"a1", "a2"}, keys)
+
+	status, _ = doRequest(t, http.MethodPost, url+"/merge", "")
+	assert.Equal(t, http.StatusNoContent, status)
+	status, body = doRequest(t, http.MethodGet, url+"/keys/b1", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "value", body)
+}
+
+func TestHandler_Stat(t *testing.T) {
+	url, cleanup := startServer(t, "")
+	defer cleanup()
+
+	for _, value := range []string{"v1", "v2"} {
+		status, _ := doRequest(t, http.MethodPut, url+"/keys/name", value)
+		assert.Equal(t, http.StatusNoContent, status)
+	}
+
+	status, body := doRequest(t, http.MethodGet, url+"/stat", "")
+	assert.Equal(t, http.StatusOK, status)
+	var stat bitcask.Stat
+	assert.Nil(t, json.Unmarshal([]byte(body), &stat))
+	assert.Equal(t, 1, stat.KeyNum)
+	assert.Equal(t, 1, stat.DataFileNum)
+	assert.True(t, stat.ReclaimableSize > 0)
+	assert.True(t, stat.DiskSize > stat.ReclaimableSize)
+}
+
+func TestHandler_Backup(t *testing.T) {
+	url, cleanup := startServer(t, "")
+	status, _ := doRequest(t, http.MethodPost, url+"/backup?dir=backup", "")
+	assert.Equal(t, http.StatusForbidden, status)
+	cleanup()
+
+	backupRoot, _ := os.MkdirTemp("", "bitcask-go-http-backup")
+	defer os.RemoveAll(backupRoot)
+	url, cleanup = startServer(t, backupRoot)
+	defer cleanup()
+
+	status, _ = doRequest(t, http.MethodPut, url+"/keys/name", "bitcask")
+	assert.Equal(t, http.StatusNoContent, status)
+
+	status, _ = doRequest(t, http.MethodPost, url+"/backup", "")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// 只能備份到備份根目錄之下
+	outside, _ := os.MkdirTemp("", "bitcask-go-http-outside")
+	defer os.RemoveAll(outside)
+	for _, dir := range []string{outside, "../backup", "a/../../backup"} {
+		status, _ = doRequest(t, http.MethodPost, url+"/backup?dir="+neturl.QueryEscape(dir), "")
+		assert.Equal(t, http.StatusBadRequest, status)
+	}
+	entries, err := os.ReadDir(outside)
+	assert.Nil(t, err)
+	assert.Empty(t, entries)
+
+	status, _ = doRequest(t, http.MethodPost, url+"/backup?dir=backup-1", "")
+	assert.Equal(t, http.StatusNoContent, status)
+	_, err = os.Stat(filepath.Join(backupRoot, "backup-1"))
+	assert.Nil(t, err)
+	status, _ = doRequest(t, http.MethodPost, url+"/backup?dir=backup-1", "")
+	assert.Equal(t, http.StatusConflict, status)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	bitcask "bitcask-go"
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8080", "the address to listen on")
+	dirPath := flag.String("dir", filepath.Join(os.TempDir(), "bitcask-go-http"), "the data directory of the database")
+	backupDir := flag.String("backup-dir", "", "the root directory that POST /backup writes into, empty to disable backups over http")
+	maxValueSize := flag.Int64("max-value-size", defaultMaxValueSize, "the max size in bytes of a value written by PUT")
+	mergeInterval := flag.Duration("merge-interval", 0, "the interval to check whether to merge in background, 0 to disable")
+	mergeRatio := flag.Float64("merge-ratio", float64(bitcask.DefaultOptions.MergeRatio), "the ratio of reclaimable data that triggers a background merge")
+	flag.Parse()
+
+	// 打開數據庫
+	opts := bitcask.DefaultOptions
+	opts.DirPath = *dirPath
+	opts.MergeInterval = *mergeInterval
+	opts.MergeRatio = float32(*mergeRatio)
+	db, err := bitcask.Open(opts)
+	if err != nil {
+		log.Fatalf("failed to open db: %v", err)
+	}
+
+	svr := &http.Server{Addr: *addr, Handler: newHandler(db, *backupDir, *maxValueSize)}
+
+	// 收到退出信號時關閉服務和數據庫
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+		<-sig
+		_ = svr.Shutdown(context.Background())
+	}()
+
+	log.Printf("bitcask http server is running on %s", *addr)
+	if err := svr.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("server stopped: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		log.Fatalf("failed to close db: %v", err)
+	}
+}
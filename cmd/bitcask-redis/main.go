@@ -0,0 +1,47 @@
+package main
+
+import (
+	bitcask "bitcask-go"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:6380", "the address to listen on")
+	dirPath := flag.String("dir", filepath.Join(os.TempDir(), "bitcask-go-redis"), "the data directory of the database")
+	mergeInterval := flag.Duration("merge-interval", 0, "the interval to check whether to merge in background, 0 to disable")
+	mergeRatio := flag.Float64("merge-ratio", float64(bitcask.DefaultOptions.MergeRatio), "the ratio of reclaimable data that triggers a background merge")
+	flag.Parse()
+
+	// 打開數據庫
+	opts := bitcask.DefaultOptions
+	opts.DirPath = *dirPath
+	opts.MergeInterval = *mergeInterval
+	opts.MergeRatio = float32(*mergeRatio)
+	db, err := bitcask.Open(opts)
+	if err != nil {
+		log.Fatalf("failed to open db: %v", err)
+	}
+
+	svr := newServer(db, *addr)
+
+	// 收到退出信號時關閉服務和數據庫
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+		<-sig
+		_ = svr.Close()
+	}()
+
+	log.Printf("bitcask redis server is running on %s", *addr)
+	if err := svr.ListenAndServe(); err != nil {
+		log.Printf("server stopped: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		log.Fatalf("failed to close db: %v", err)
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	bitcask "bitcask-go"
+	"errors"
+	"fmt"
+	"github.com/tidwall/match"
+	"github.com/tidwall/redcon"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// 默認每次 SCAN 檢查的 key 的數量
+	defaultScanCount = 10
+	// 最多保留的 SCAN 游標數量，超出後最早分配的游標失效
+	maxScanCursors = 1024
+)
+
+type cmdHandler func(svr *server, conn redcon.Conn, args [][]byte)
+
+// supportedCommands 支持的命令以及對應的處理方法，args 不包含命令名稱
+var supportedCommands = map[string]cmdHandler{
+	"ping":   ping,
+	"get":    get,
+	"set":    set,
+	"del":    del,
+	"exists": exists,
+	"expire": expire,
+	"keys":   keys,
+	"scan":   scan,
+}
+
+// server 兼容 RESP2 協議的服務，將 redis 命令映射到數據庫的操作
+type server struct {
+	db *bitcask.DB
+	mu *sync.Mutex // 寫命令串行執行，保證 EXPIRE 這類先讀後寫的命令的原子性
+	*redcon.Server
+
+	cursorMu   *sync.Mutex
+	cursors    map[uint64][]byte // SCAN 游標對應的上一次檢查到的最後一個 key
+	nextCursor uint64
+}
+
+func newServer(db *bitcask.DB, addr string) *server {
+	svr := &server{
+		db:       db,
+		mu:       new(sync.Mutex),
+		cursorMu: new(sync.Mutex),
+		cursors:  make(map[uint64][]byte),
+	}
+	svr.Server = redcon.NewServer(addr, svr.handle, nil, nil)
+	return svr
+}
+
+func (svr *server) handle(conn redcon.Conn, cmd redcon.Command) {
+	name := strings.ToLower(string(cmd.Args[0]))
+	if name == "quit" {
+		conn.WriteString("OK")
+		_ = conn.Close()
+		return
+	}
+	handler, ok := supportedCommands[name]
+	if !ok {
+		conn.WriteError(fmt.Sprintf("ERR unknown command '%s'", cmd.Args[0]))
+		return
+	}
+	handler(svr, conn, cmd.Args[1:])
+}
+
+func writeArgsNumError(conn redcon.Conn, name string) {
+	conn.WriteError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", name))
+}
+
+func ping(svr *server, conn redcon.Conn, args [][]byte) {
+	if len(args) > 1 {
+		writeArgsNumError(conn, "ping")
+		return
+	}
+	if len(args) == 1 {
+		conn.WriteBulk(args[0])
+		return
+	}
+	conn.WriteString("PONG")
+}
+
+func get(svr *server, conn redcon.Conn, args [][]byte) {
+	if len(args) != 1 {
+		writeArgsNumError(conn, "get")
+		return
+	}
+	value, err := svr.db.Get(args[0])
+	if err != nil {
+		if errors.Is(err, bitcask.ErrKeyNotFound) {
+			conn.WriteNull()
+			return
+		}
+		conn.WriteError("ERR " + err.Error())
+		return
+	}
+	conn.WriteBulk(value)
+}
+
+// set 支持 SET key value [EX seconds | PX milliseconds]
+func set(svr *server, conn redcon.Conn, args [][]byte) {
+	if len(args) != 2 && len(args) != 4 {
+		writeArgsNumError(conn, "set")
+		return
+	}
+	var ttl time.Duration
+	if len(args) == 4 {
+		n, err := strconv.ParseInt(string(args[3]), 10, 64)
+		if err != nil || n <= 0 {
+			conn.WriteError("ERR invalid expire time in 'set' command")
+			return
+		}
+		switch strings.ToLower(string(args[2])) {
+		case "ex":
+			ttl = time.Duration(n) * time.Second
+		case "px":
+			ttl = time.Duration(n) * time.Millisecond
+		default:
+			conn.WriteError("ERR syntax error")
+			return
+		}
+	}
+
+	svr.mu.Lock()
+	defer svr.mu.Unlock()
+	var err error
+	if ttl > 0 {
+		err = svr.db.PutWithTTL(args[0], args[1], ttl)
+	} else {
+		err = svr.db.Put(args[0], args[1])
+	}
+	if err != nil {
+		conn.WriteError("ERR " + err.Error())
+		return
+	}
+	conn.WriteString("OK")
+}
+
+// del 返回實際刪除的 key 的數量
+func del(svr *server, conn redcon.Conn, args [][]byte) {
+	if len(args) == 0 {
+		writeArgsNumError(conn, "del")
+		return
+	}
+
+	svr.mu.Lock()
+	defer svr.mu.Unlock()
+	deleted := 0
+	for _, key := range args {
+		ok, err := svr.keyExists(key)
+		if err != nil {
+			conn.WriteError("ERR " + err.Error())
+			return
+		}
+		if !ok {
+			continue
+		}
+		if err := svr.db.Delete(key); err != nil {
+			conn.WriteError("ERR " + err.Error())
+			return
+		}
+		deleted++
+	}
+	conn.WriteInt(deleted)
+}
+
+// exists 返回存在的 key 的數量，重複的 key 會被重複計算
+func exists(svr *server, conn redcon.Conn, args [][]byte) {
+	if len(args) == 0 {
+		writeArgsNumError(conn, "exists")
+		return
+	}
+	count := 0
+	for _, key := range args {
+		ok, err := svr.keyExists(key)
+		if err != nil {
+			conn.WriteError("ERR " + err.Error())
+			return
+		}
+		if ok {
+			count++
+		}
+	}
+	conn.WriteInt(count)
+}
+
+// expire 重新寫入帶有過期時間的數據，過期時間不為正數時直接刪除 key
+func expire(svr *server, conn redcon.Conn, args [][]byte) {
+	if len(args) != 2 {
+		writeArgsNumError(conn, "expire")
+		return
+	}
+	seconds, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		conn.WriteError("ERR value is not an integer or out of range")
+		return
+	}
+
+	svr.mu.Lock()
+	defer svr.mu.Unlock()
+	value, err := svr.db.Get(args[0])
+	if err != nil {
+		if errors.Is(err, bitcask.ErrKeyNotFound) {
+			conn.WriteInt(0)
+			return
+		}
+		conn.WriteError("ERR " + err.Error())
+		return
+	}
+	if seconds <= 0 {
+		err = svr.db.Delete(args[0])
+	} else {
+		err = svr.db.PutWithTTL(args[0], value, time.Duration(seconds)*time.Second)
+	}
+	if err != nil {
+		conn.WriteError("ERR " + err.Error())
+		return
+	}
+	conn.WriteInt(1)
+}
+
+func keys(svr *server, conn redcon.Conn, args [][]byte) {
+	if len(args) != 1 {
+		writeArgsNumError(conn, "keys")
+		return
+	}
+	pattern := string(args[0])
+	result, _ := svr.scanKeys(nil, -1, pattern)
+	conn.WriteArray(len(result))
+	for _, key := range result {
+		conn.WriteBulk(key)
+	}
+}
+
+// scan 支持 SCAN cursor [MATCH pattern] [COUNT count]
+// 客戶端要求游標是整數，因此由服務端記錄每個游標對應的上一次檢查到的最後一個 key，
+// 下一次從這個 key 之後繼續遍歷，遍歷期間刪除 key 不會導致跳過其他的 key，遍歷結束時返回 0
+func scan(svr *server, conn redcon.Conn, args [][]byte) {
+	if len(args) == 0 || len(args)%2 != 1 {
+		writeArgsNumError(conn, "scan")
+		return
+	}
+	cursor, err := strconv.ParseUint(string(args[0]), 10, 64)
+	if err != nil {
+		conn.WriteError("ERR invalid cursor")
+		return
+	}
+	var last []byte
+	if cursor != 0 {
+		if last = svr.cursorKey(cursor); last == nil {
+			conn.WriteError("ERR invalid cursor")
+			return
+		}
+	}
+	pattern, count := "*", defaultScanCount
+	for i := 1; i < len(args); i += 2 {
+		switch strings.ToLower(string(args[i])) {
+		case "match":
+			pattern = string(args[i+1])
+		case "count":
+			count, err = strconv.Atoi(string(args[i+1]))
+			if err != nil || count <= 0 {
+				conn.WriteError("ERR value is not an integer or out of range")
+				return
+			}
+		default:
+			conn.WriteError("ERR syntax error")
+			return
+		}
+	}
+
+	result, last := svr.scanKeys(last, count, pattern)
+	var next uint64
+	if last != nil {
+		next = svr.newCursor(last)
+	}
+	conn.WriteArray(2)
+	conn.WriteBulkString(strconv.FormatUint(next, 10))
+	conn.WriteArray(len(result))
+	for _, key := range result {
+		conn.WriteBulk(key)
+	}
+}
+
+// scanKeys 從 after 之後的第一個 key 開始（after 為 nil 時從頭開始）檢查最多 count 個 key（count 為負數表示不限制），
+// 返回其中匹配的 key 以及最後檢查的 key，所有的 key 都已經檢查過時返回的最後一個 key 為 nil
+func (svr *server) scanKeys(after []byte, count int, pattern string) ([][]byte, []byte) {
+	iter := svr.db.NewIterator(bitcask.DefaultIteratorOptions)
+	defer iter.Close()
+	if after == nil {
+		iter.Rewind()
+	} else {
+		// 在 key 後面追加一個 0 得到的是比它大的最小的 key
+		iter.Seek(append(append([]byte(nil), after...), 0))
+	}
+
+	var result [][]byte
+	var last []byte
+	for examined := 0; iter.Valid(); iter.Next() {
+		if count >= 0 && examined == count {
+			return result, last
+		}
+		examined++
+		last = append([]byte(nil), iter.Key()...)
+		if match.Match(string(last), pattern) {
+			result = append(result, last)
+		}
+	}
+	return result, nil
+}
+
+// newCursor 分配一個新的 SCAN 游標，記錄本次檢查到的最後一個 key
+func (svr *server) newCursor(last []byte) uint64 {
+	svr.cursorMu.Lock()
+	defer svr.cursorMu.Unlock()
+	svr.nextCursor++
+	svr.cursors[svr.nextCursor] = last
+	if svr.nextCursor > maxScanCursors {
+		delete(svr.cursors, svr.nextCursor-maxScanCursors)
+	}
+	return svr.nextCursor
+}
+
+// cursorKey 獲取游標對應的最後一個 key，游標不存在或已經失效時返回 nil
+func (svr *server) cursorKey(cursor uint64) []byte {
+	svr.cursorMu.Lock()
+	defer svr.cursorMu.Unlock()
+	return svr.cursors[cursor]
+}
+
+func (svr *server) keyExists(key []byte) (bool, error) {
+	_, err := svr.db.Get(key)
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, bitcask.ErrKeyNotFound) {
+		return false, nil
+	}
+	return false, err
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	bitcask "bitcask-go"
+	"context"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"os"
+	"sort"
+	"testing"
+	"time"
+)
+
+// startServer 在隨機端口上啟動服務，返回連接到該服務的客戶端以及清理函數
+func startServer(t *testing.T) (*redis.Client, func()) {
+	opts := bitcask.DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-redis")
+	opts.DirPath = dir
+	db, err := bitcask.Open(opts)
+	assert.Nil(t, err)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	svr := newServer(db, ln.Addr().String())
+	go func() {
+		_ = svr.Serve(ln)
+	}()
+
+	client := redis.NewClient(&redis.Options{Addr: ln.Addr().String()})
+	return client, func() {
+		_ = client.Close()
+		_ = svr.Close()
+		_ = db.Close()
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func TestServer_Commands(t *testing.T) {
+	client, cleanup := startServer(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	pong, err := client.Ping(ctx).Result()
+	assert.Nil(t, err)
+	assert.Equal(t, "PONG", pong)
+
+	_, err = client.Get(ctx, "name").Result()
+	assert.Equal(t, redis.Nil, err)
+
+	err = client.Set(ctx, "name", "bitcask", 0).Err()
+	assert.Nil(t, err)
+	val, err := client.Get(ctx, "name").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, "bitcask", val)
+
+	n, err := client.Exists(ctx, "name", "unknown", "name").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), n)
+
+	n, err = client.Del(ctx, "name", "unknown").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), n)
+	_, err = client.Get(ctx, "name").Result()
+	assert.Equal(t, redis.Nil, err)
+
+	err = client.Do(ctx, "unknown-cmd").Err()
+	assert.NotNil(t, err)
+}
+
+func TestServer_Expire(t *testing.T) {
+	client, cleanup := startServer(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	err := client.Set(ctx, "k1", "v1", 100*time.Millisecond).Err()
+	assert.Nil(t, err)
+	err = client.Set(ctx, "k2", "v2", 0).Err()
+	assert.Nil(t, err)
+
+	ok, err := client.Expire(ctx, "k2", time.Second).Result()
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = client.Expire(ctx, "unknown", time.Second).Result()
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	time.Sleep(200 * time.Millisecond)
+	_, err = client.Get(ctx, "k1").Result()
+	assert.Equal(t, redis.Nil, err)
+	val, err := client.Get(ctx, "k2").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, "v2", val)
+
+	// 過期的 key 不會出現在 KEYS 的結果中
+	keys, err := client.Keys(ctx, "*").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"k2"}, keys)
+}
+
+func TestServer_KeysAndScan(t *testing.T) {
+	client, cleanup := startServer(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	for i := 0; i < 25; i++ {
+		err := client.Set(ctx, fmt.Sprintf("user:%02d", i), "value", 0).Err()
+		assert.Nil(t, err)
+	}
+	for i := 0; i < 5; i++ {
+		err := client.Set(ctx, fmt.Sprintf("order:%02d", i), "value", 0).Err()
+		assert.Nil(t, err)
+	}
+
+	keys, err := client.Keys(ctx, "order:*").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"order:00", "order:01", "order:02", "order:03", "order:04"}, keys)
+
+	// 通過游標遍歷所有的 key
+	var all []string
+	var cursor uint64
+	for {
+		var page []string
+		page, cursor, err = client.Scan(ctx, cursor, "user:*", 7).Result()
+		assert.Nil(t, err)
+		all = append(all, page...)
+		if cursor == 0 {
+			break
+		}
+	}
+	sort.Strings(all)
+	assert.Equal(t, 25, len(all))
+	assert.Equal(t, "user:00", all[0])
+	assert.Equal(t, "user:24", all[24])
+}
+
+func TestServer_ScanWithDeletes(t *testing.T) {
+	client, cleanup := startServer(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	for i := 0; i < 30; i++ {
+		err := client.Set(ctx, fmt.Sprintf("key:%02d", i), "value", 0).Err()
+		assert.Nil(t, err)
+	}
+
+	// 遍歷期間刪除已經返回的 key，不能跳過剩下的 key
+	var all []string
+	var cursor uint64
+	for {
+		page, next, err := client.Scan(ctx, cursor, "*", 4).Result()
+		assert.Nil(t, err)
+		all = append(all, page...)
+		for _, key := range page {
+			err = client.Del(ctx, key).Err()
+			assert.Nil(t, err)
+		}
+		cursor = next
+		if cursor == 0 {
+			break
+		}
+	}
+	assert.Equal(t, 30, len(all))
+	assert.Equal(t, "key:00", all[0])
+	assert.Equal(t, "key:29", all[29])
+
+	_, _, err := client.Scan(ctx, 12345, "*", 4).Result()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	bitcask "bitcask-go"
+	"bitcask-go/index"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// cmdContext 需要打開數據庫的命令的上下文
+type cmdContext struct {
+	db      *bitcask.DB
+	dirPath string
+	args    []string
+	out     io.Writer
+}
+
+type cmdHandler func(c *cmdContext) error
+
+var commands = map[string]cmdHandler{
+	"get":    get,
+	"put":    put,
+	"delete": del,
+	"scan":   scan,
+	"stat":   stat,
+	"merge":  merge,
+}
+
+// dbOptions 根據命令行參數生成打開數據庫的配置
+// 沒有指定索引類型時，目錄中存在 B+ 樹索引文件則使用 B+ 樹索引，避免使用其他索引打開時索引文件被刪除
+// 指定了密鑰但沒有指定當前密鑰時使用 id 最大的密鑰，避免新寫入以及 merge 之後的數據變成明文
+func dbOptions(dirPath, indexName string, keys map[uint32][]byte, keyId uint32) (bitcask.Options, error) {
+	opts := bitcask.DefaultOptions
+	opts.DirPath = dirPath
+
+	switch indexName {
+	case "":
+		if _, err := os.Stat(filepath.Join(dirPath, index.BPlusTreeIndexFileName)); err == nil {
+			opts.IndexType = bitcask.BPlusTree
+		}
+	case "btree":
+		opts.IndexType = bitcask.Btree
+	case "art":
+		opts.IndexType = bitcask.ART
+	case "bptree":
+		opts.IndexType = bitcask.BPlusTree
+	default:
+		return opts, fmt.Errorf("unknown index type %q", indexName)
+	}
+
+	if len(keys) > 0 {
+		opts.EncryptionKeys = keys
+		if keyId == 0 {
+			for id := range keys {
+				keyId = max(keyId, id)
+			}
+		}
+	}
+	if _, ok := keys[keyId]; keyId != 0 && !ok {
+		return opts, fmt.Errorf("the encryption key %d is not given", keyId)
+	}
+	opts.EncryptionKeyId = keyId
+	return opts, nil
+}
+
+// parseKey 解析 id:hex 格式的密鑰
+func parseKey(keys map[uint32][]byte, s string) error {
+	idStr, hexKey, ok := strings.Cut(s, ":")
+	if !ok {
+		return errors.New("the key must be in the form id:hex")
+	}
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil || id == 0 {
+		return fmt.Errorf("invalid key id %q", idStr)
+	}
+	key, err := hex.DecodeString(hexKey)
+	if err != nil {
+		return fmt.Errorf("invalid key %d: %w", id, err)
+	}
+	keys[uint32(id)] = key
+	return nil
+}
+
+// withDB 打開數據庫執行命令，執行完之後關閉數據庫
+func withDB(opts bitcask.Options, fn func(c *cmdContext) error) error {
+	db, err := bitcask.Open(opts)
+	if err != nil {
+		return err
+	}
+	err = fn(&cmdContext{db: db, dirPath: opts.DirPath})
+	if closeErr := db.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func checkArgs(c *cmdContext, name string, min, max int) error {
+	if len(c.args) < min || len(c.args) > max {
+		return fmt.Errorf("wrong number of arguments for %q", name)
+	}
+	return nil
+}
+
+func get(c *cmdContext) error {
+	if err := checkArgs(c, "get", 1, 1); err != nil {
+		return err
+	}
+	value, err := c.db.Get([]byte(c.args[0]))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.out, "%s\n", value)
+	return err
+}
+
+func put(c *cmdContext) error {
+	fs := flag.NewFlagSet("put", flag.ContinueOnError)
+	ttl := fs.Duration("ttl", 0, "the time to live of the key, never expires if not set")
+	if err := fs.Parse(c.args); err != nil {
+		return err
+	}
+	c.args = fs.Args()
+	if err := checkArgs(c, "put", 2, 2); err != nil {
+		return err
+	}
+	key, value := []byte(c.args[0]), []byte(c.args[1])
+	if *ttl > 0 {
+		return c.db.PutWithTTL(key, value, *ttl)
+	}
+	return c.db.Put(key, value)
+}
+
+func del(c *cmdContext) error {
+	if err := checkArgs(c, "delete", 1, 1); err != nil {
+		return err
+	}
+	return c.db.Delete([]byte(c.args[0]))
+}
+
+func scan(c *cmdContext) error {
+	if err := checkArgs(c, "scan", 0, 1); err != nil {
+		return err
+	}
+	iterOpts := bitcask.DefaultIteratorOptions
+	if len(c.args) == 1 {
+		iterOpts.Prefix = []byte(c.args[0])
+	}
+
+	iter := c.db.NewIterator(iterOpts)
+	defer iter.Close()
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		value, err := iter.Value()
+		if errors.Is(err, bitcask.ErrKeyNotFound) {
+			// 遍歷的過程中剛好過期的數據
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(c.out, "%s\t%s\n", iter.Key(), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func stat(c *cmdContext) error {
+	if err := checkArgs(c, "stat", 0, 0); err != nil {
+		return err
+	}
+	stat, err := c.db.Stat()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.out, "keys: %d\ndata files: %d\nreclaimable size: %d\ndisk size: %d\n",
+		stat.KeyNum, stat.DataFileNum, stat.ReclaimableSize, stat.DiskSize)
+	return err
+}
+
+func merge(c *cmdContext) error {
+	if err := checkArgs(c, "merge", 0, 0); err != nil {
+		return err
+	}
+	return c.db.Merge()
+}
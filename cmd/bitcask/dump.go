@@ -0,0 +1,115 @@
+package main
+
+import (
+	bitcask "bitcask-go"
+	"bitcask-go/data"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"time"
+)
+
+// rawRecord 從數據文件中直接解碼出的記錄
+type rawRecord struct {
+	fid      uint32
+	offset   int64
+	header   *data.LogRecordHeader
+	key      []byte // 去掉序列號之後的 key
+	seqNo    uint64
+	inTxn    bool
+	crcValid bool
+}
+
+// dump 逐條解碼數據文件中的記錄，不需要打開數據庫
+// 加密記錄的 key 無法解析，只輸出 header 中的信息
+func dump(dirPath string, out io.Writer) error {
+	return walkDataFiles(dirPath, func(r *rawRecord) error {
+		expiry := "-"
+		if r.header.Expiry() > 0 {
+			expiry = time.Unix(0, r.header.Expiry()).Format(time.RFC3339)
+		}
+		crcStatus := "ok"
+		if !r.crcValid {
+			crcStatus = "BAD"
+		}
+		_, err := fmt.Fprintf(out, "fid=%d offset=%d type=%s seq=%d txn=%t key=%q value_size=%d compression=%s encrypted=%t expiry=%s crc=%s\n",
+			r.fid, r.offset, recordTypeName(r.header.Type()), r.seqNo, r.inTxn, r.key, r.header.ValueSize(),
+			compressionName(r.header.Compression()), r.header.Encrypted(), expiry, crcStatus)
+		return err
+	})
+}
+
+// walkDataFiles 按照文件 id 的順序遍歷所有數據文件中的記錄
+// 文件末尾不完整的記錄會被忽略，可以通過 verify 命令查看
+func walkDataFiles(dirPath string, fn func(r *rawRecord) error) error {
+	fileIds, err := bitcask.ListDataFileIds(dirPath)
+	if err != nil {
+		return err
+	}
+
+	for _, fid := range fileIds {
+		buf, err := os.ReadFile(data.GetDataFileName(dirPath, fid))
+		if err != nil {
+			return err
+		}
+
+		// 記錄從文件頭之後開始，文件頭損壞時跳過文件頭繼續解析，損壞的情況可以通過 verify 命令查看
+		offset := int64(data.FileHeaderSize)
+		fileHeader, err := data.DecodeFileHeader(buf)
+		if err == nil {
+			offset = fileHeader.Size()
+		} else if err != data.ErrInvalidFileHeader {
+			return err
+		}
+		for offset < int64(len(buf)) {
+			header, headerSize := data.DecodeLogRecordHeader(buf[offset:])
+			// 讀取到了文件末尾
+			if header == nil || (header.Crc() == 0 && header.KeySize() == 0 && header.ValueSize() == 0) {
+				break
+			}
+			recordSize := headerSize + int64(header.KeySize()) + int64(header.ValueSize())
+			if offset+recordSize > int64(len(buf)) {
+				break
+			}
+
+			record := &rawRecord{
+				fid:      fid,
+				offset:   offset,
+				header:   header,
+				crcValid: crc32.ChecksumIEEE(buf[offset+crc32.Size:offset+recordSize]) == header.Crc(),
+			}
+			key := buf[offset+headerSize : offset+headerSize+int64(header.KeySize())]
+			record.key, record.seqNo, record.inTxn = bitcask.ParseLogRecordKey(key)
+			if err := fn(record); err != nil {
+				return err
+			}
+			offset += recordSize
+		}
+	}
+	return nil
+}
+
+func recordTypeName(typ data.LogRecordType) string {
+	switch typ {
+	case data.LogRecordNormal:
+		return "normal"
+	case data.LogRecordDeleted:
+		return "deleted"
+	case data.LogRecordTxnFinished:
+		return "txn-finished"
+	}
+	return fmt.Sprintf("unknown(%d)", typ)
+}
+
+func compressionName(compression data.CompressionType) string {
+	switch compression {
+	case data.NoCompression:
+		return "none"
+	case data.Snappy:
+		return "snappy"
+	case data.Zstd:
+		return "zstd"
+	}
+	return fmt.Sprintf("unknown(%d)", compression)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+const usage = `usage: bitcask -dir <path> [-index type] [-key id:hex]... [-key-id id] <command> [arguments]
+
+options:
+  -index btree|art|bptree       the index type, bptree if the directory has a B+ tree index file, btree otherwise
+  -key id:hex                   an AES encryption key in hex, can be repeated for rotated keys
+  -key-id id                    the id of the key used to encrypt new records, the largest key id by default
+
+commands:
+  get <key>                     print the value of the key
+  put [-ttl duration] <key> <value>
+                                write the key
+  delete <key>                  delete the key
+  scan [prefix]                 print all the keys and values with the prefix
+  stat                          print the statistics of the database
+  merge                         merge the data files
+  dump                          decode every record in the data files, works offline
+  verify                        report the corrupted ranges in the data files, works offline
+  repair                        remove the corrupted ranges so that the database can be opened
+`
+
+// errCorrupted 數據文件中存在損壞的記錄，命令以非零狀態碼退出
+var errCorrupted = errors.New("data files are corrupted")
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "bitcask:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("bitcask", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(fs.Output(), usage) }
+	dirPath := fs.String("dir", "", "the data directory of the database")
+	indexName := fs.String("index", "", "the index type, btree, art or bptree")
+	keys := make(map[uint32][]byte)
+	fs.Func("key", "an AES encryption key in the form id:hex, can be repeated", func(s string) error {
+		return parseKey(keys, s)
+	})
+	keyId := fs.Uint("key-id", 0, "the id of the key used to encrypt new records")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dirPath == "" || fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("missing data directory or command")
+	}
+
+	cmd, cmdArgs := fs.Arg(0), fs.Args()[1:]
+	switch cmd {
+	case "dump":
+		return dump(*dirPath, out)
+	case "verify":
+		return verify(*dirPath, out)
+	case "repair":
+		return repair(*dirPath, out)
+	}
+
+	handler, ok := commands[cmd]
+	if !ok {
+		fs.Usage()
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+	opts, err := dbOptions(*dirPath, *indexName, keys, uint32(*keyId))
+	if err != nil {
+		return err
+	}
+	return withDB(opts, func(c *cmdContext) error {
+		c.args, c.out = cmdArgs, out
+		return handler(c)
+	})
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	bitcask "bitcask-go"
+	"bitcask-go/data"
+	"bitcask-go/index"
+	"bytes"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runCmd(t *testing.T, dir string, args ...string) (string, error) {
+	var out bytes.Buffer
+	err := run(append([]string{"-dir", dir}, args...), &out)
+	return out.String(), err
+}
+
+func TestRun_Commands(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "bitcask-go-cli")
+	defer os.RemoveAll(dir)
+
+	_, err := runCmd(t, dir, "put", "name", "bitcask")
+	assert.Nil(t, err)
+	_, err = runCmd(t, dir, "put", "-ttl", "1h", "lang", "go")
+	assert.Nil(t, err)
+
+	out, err := runCmd(t, dir, "get", "name")
+	assert.Nil(t, err)
+	assert.Equal(t, "bitcask\n", out)
+
+	out, err = runCmd(t, dir, "scan")
+	assert.Nil(t, err)
+	assert.Equal(t, "lang\tgo\nname\tbitcask\n", out)
+
+	_, err = runCmd(t, dir, "delete", "name")
+	assert.Nil(t, err)
+	_, err = runCmd(t, dir, "get", "name")
+	assert.NotNil(t, err)
+
+	out, err = runCmd(t, dir, "stat")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(out, "keys: 1\ndata files: 1\n"))
+	assert.Contains(t, out, "reclaimable size: ")
+
+	_, err = runCmd(t, dir, "merge")
+	assert.Nil(t, err)
+
+	_, err = runCmd(t, dir, "unknown")
+	assert.NotNil(t, err)
+	_, err = runCmd(t, dir, "get")
+	assert.NotNil(t, err)
+}
+
+func TestRun_DumpAndVerify(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "bitcask-go-cli-dump")
+	defer os.RemoveAll(dir)
+
+	_, err := runCmd(t, dir, "put", "name", "bitcask")
+	assert.Nil(t, err)
+	_, err = runCmd(t, dir, "delete", "name")
+	assert.Nil(t, err)
+
+	out, err := runCmd(t, dir, "dump")
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	assert.Equal(t, 2, len(lines))
+	assert.Contains(t, lines[0], "type=normal seq=1 txn=false key=\"name\" value_size=7 compression=none encrypted=false")
+	assert.Contains(t, lines[1], "type=deleted seq=2")
+
+	out, err = runCmd(t, dir, "verify")
+	assert.Nil(t, err)
+	assert.Contains(t, out, "corrupted ranges: 0")
+
+	// 和數據庫一樣，無法解析的數據文件名視為數據目錄損壞
+	invalidName := filepath.Join(dir, "invalid"+data.FileNameSuffix)
+	assert.Nil(t, os.WriteFile(invalidName, nil, 0644))
+	_, err = runCmd(t, dir, "dump")
+	assert.Equal(t, bitcask.ErrDataDirectoryCorrupted, err)
+	assert.Nil(t, os.Remove(invalidName))
+
+	// 文件末尾長度信息無效的數據不會導致崩潰
+	fileName := data.GetDataFileName(dir, 0)
+	buf, err := os.ReadFile(fileName)
+	assert.Nil(t, err)
+	assert.Nil(t, os.WriteFile(fileName, append(buf, bytes.Repeat([]byte{0xff}, 64)...), 0644))
+	out, err = runCmd(t, dir, "dump")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(strings.Split(strings.TrimSpace(out), "\n")))
+	out, err = runCmd(t, dir, "verify")
+	assert.Equal(t, errCorrupted, err)
+	assert.Contains(t, out, "corrupted ranges: 1")
+	assert.Nil(t, os.WriteFile(fileName, buf, 0644))
+
+	// 修改數據文件中的一個字節之後校驗失敗
+	buf[len(buf)-1] ^= 0xff
+	assert.Nil(t, os.WriteFile(fileName, buf, 0644))
+
+	out, err = runCmd(t, dir, "verify")
+	assert.Equal(t, errCorrupted, err)
+	assert.Contains(t, out, "corrupted ranges: 1")
+
+	out, err = runCmd(t, dir, "repair")
+	assert.Nil(t, err)
+	assert.Contains(t, out, "repaired ranges: 1")
+	_, err = runCmd(t, dir, "verify")
+	assert.Nil(t, err)
+
+	// 損壞的刪除記錄被移除之後，數據重新可見
+	out, err = runCmd(t, dir, "get", "name")
+	assert.Nil(t, err)
+	assert.Equal(t, "bitcask\n", out)
+}
+
+func TestRun_IndexAndEncryptionOptions(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "bitcask-go-cli-options")
+	defer os.RemoveAll(dir)
+
+	key := bytes.Repeat([]byte{0x01}, 16)
+	opts := bitcask.DefaultOptions
+	opts.DirPath = dir
+	opts.IndexType = bitcask.BPlusTree
+	opts.EncryptionKeys = map[uint32][]byte{1: key}
+	opts.EncryptionKeyId = 1
+	db, err := bitcask.Open(opts)
+	assert.Nil(t, err)
+	assert.Nil(t, db.Put([]byte("name"), []byte("bitcask")))
+	assert.Nil(t, db.Close())
+
+	// 沒有提供密鑰時無法打開加密的數據庫
+	_, err = runCmd(t, dir, "get", "name")
+	assert.NotNil(t, err)
+
+	keyArg := "1:" + hex.EncodeToString(key)
+	out, err := runCmd(t, dir, "-key", keyArg, "get", "name")
+	assert.Nil(t, err)
+	assert.Equal(t, "bitcask\n", out)
+	// 自動識別 B+ 樹索引，索引文件不會被刪除
+	_, err = os.Stat(filepath.Join(dir, index.BPlusTreeIndexFileName))
+	assert.Nil(t, err)
+
+	// 新寫入的數據默認使用 id 最大的密鑰加密
+	_, err = runCmd(t, dir, "-index", "bptree", "-key", keyArg, "put", "lang", "go")
+	assert.Nil(t, err)
+	out, err = runCmd(t, dir, "dump")
+	assert.Nil(t, err)
+	assert.NotContains(t, out, "encrypted=false")
+
+	_, err = runCmd(t, dir, "-index", "unknown", "stat")
+	assert.NotNil(t, err)
+	_, err = runCmd(t, dir, "-key", "1:not-hex", "stat")
+	assert.NotNil(t, err)
+	_, err = runCmd(t, dir, "-key", keyArg, "-key-id", "2", "stat")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	bitcask "bitcask-go"
+	"fmt"
+	"io"
+)
+
+// verify 輸出數據文件中所有損壞的範圍，存在損壞時返回錯誤
+func verify(dirPath string, out io.Writer) error {
+	ranges, err := bitcask.Verify(dirPath)
+	if err != nil {
+		return err
+	}
+	if err := printRanges(out, "corrupted", ranges); err != nil {
+		return err
+	}
+	if len(ranges) > 0 {
+		return errCorrupted
+	}
+	return nil
+}
+
+// repair 修復損壞的數據文件，輸出修復的範圍
+func repair(dirPath string, out io.Writer) error {
+	ranges, err := bitcask.Repair(dirPath)
+	if err != nil {
+		return err
+	}
+	return printRanges(out, "repaired", ranges)
+}
+
+func printRanges(out io.Writer, action string, ranges []bitcask.CorruptRange) error {
+	for _, r := range ranges {
+		if _, err := fmt.Fprintf(out, "%s: %s\n", action, r); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(out, "%s ranges: %d\n", action, len(ranges))
+	return err
+}
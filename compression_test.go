@@ -0,0 +1,84 @@
+package bitcask_go
+
+import (
+	"bitcask-go/data"
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestDB_Compression(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-compression")
+	opts.DirPath = dir
+	opts.Compression = Snappy
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	value := bytes.Repeat([]byte(`{"name":"bitcask","lang":"go"}`), 100)
+	assert.Nil(t, db.Put(getTestKey(1), value))
+	// 壓縮後沒有變小的數據按原樣保存
+	assert.Nil(t, db.Put(getTestKey(2), []byte("v")))
+	assert.True(t, db.activeFile.WriteOffset < int64(len(value)))
+	// 配置的壓縮算法和數據文件中保存的值一致
+	record, _, err := db.activeFile.ReadLogRecord(db.index.Get(getTestKey(1)).Offset)
+	assert.Nil(t, err)
+	assert.Equal(t, data.Snappy, record.Compression)
+
+	val, err := db.Get(getTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, value, val)
+	val, err = db.Get(getTestKey(2))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("v"), val)
+
+	// 使用不同的壓縮算法重新打開，已有的數據仍然可以讀取
+	assert.Nil(t, db.Close())
+	opts.Compression = Zstd
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	assert.Nil(t, db2.Put(getTestKey(3), value))
+	assert.Nil(t, db2.Close())
+
+	opts.Compression = NoCompression
+	db3, err := Open(opts)
+	assert.Nil(t, err)
+	defer destroyDB(db3)
+	assert.Nil(t, db3.Put(getTestKey(4), value))
+	for _, i := range []int{1, 3, 4} {
+		val, err := db3.Get(getTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, value, val)
+	}
+
+	// merge 之後壓縮的數據保持不變
+	assert.Nil(t, db3.Merge())
+	assert.Nil(t, db3.Close())
+	db4, err := Open(opts)
+	assert.Nil(t, err)
+	defer destroyDB(db4)
+	iter := db4.NewIterator(DefaultIteratorOptions)
+	defer iter.Close()
+	count := 0
+	for ; iter.Valid(); iter.Next() {
+		val, err := iter.Value()
+		assert.Nil(t, err)
+		if !bytes.Equal(iter.Key(), getTestKey(2)) {
+			assert.Equal(t, value, val)
+		}
+		count++
+	}
+	assert.Equal(t, 4, count)
+}
+
+func TestDB_InvalidCompression(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-compression-2")
+	defer os.RemoveAll(dir)
+	opts.DirPath = dir
+	opts.Compression = 10
+	_, err := Open(opts)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,47 @@
+package data
+
+import (
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// CompressionType value 的壓縮算法，保存在記錄 header 中類型字節的高四位
+type CompressionType = byte
+
+const (
+	NoCompression CompressionType = iota
+	Snappy
+	Zstd
+)
+
+// zstd 的編解碼器可以被多個協程同時使用 EncodeAll/DecodeAll
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// CompressValue 使用指定的算法壓縮 value
+func CompressValue(compression CompressionType, value []byte) ([]byte, error) {
+	switch compression {
+	case NoCompression:
+		return value, nil
+	case Snappy:
+		return snappy.Encode(nil, value), nil
+	case Zstd:
+		return zstdEncoder.EncodeAll(value, nil), nil
+	}
+	return nil, ErrUnknownCompression
+}
+
+// DecompressValue 使用指定的算法解壓 value
+func DecompressValue(compression CompressionType, value []byte) ([]byte, error) {
+	switch compression {
+	case NoCompression:
+		return value, nil
+	case Snappy:
+		return snappy.Decode(nil, value)
+	case Zstd:
+		return zstdDecoder.DecodeAll(value, nil)
+	}
+	return nil, ErrUnknownCompression
+}
@@ -0,0 +1,59 @@
+package data
+
+import (
+	"bitcask-go/fio"
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestCompressValue(t *testing.T) {
+	value := bytes.Repeat([]byte(`{"name":"bitcask","lang":"go"}`), 100)
+	for _, compression := range []CompressionType{NoCompression, Snappy, Zstd} {
+		compressed, err := CompressValue(compression, value)
+		assert.Nil(t, err)
+		if compression != NoCompression {
+			assert.True(t, len(compressed) < len(value))
+		}
+		decompressed, err := DecompressValue(compression, compressed)
+		assert.Nil(t, err)
+		assert.Equal(t, value, decompressed)
+	}
+
+	_, err := CompressValue(10, value)
+	assert.Equal(t, ErrUnknownCompression, err)
+	_, err = DecompressValue(10, value)
+	assert.Equal(t, ErrUnknownCompression, err)
+
+	// 損壞的壓縮數據解壓失敗
+	_, err = DecompressValue(Zstd, []byte("not compressed"))
+	assert.NotNil(t, err)
+}
+
+func TestDataFile_ReadCompressedLogRecord(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "bitcask-go-compression")
+	defer os.RemoveAll(dir)
+	dataFile, err := OpenDataFile(dir, 0, fio.StandardFIO)
+	assert.Nil(t, err)
+	defer dataFile.Close()
+
+	value := bytes.Repeat([]byte("bitcask"), 100)
+	compressed, err := CompressValue(Snappy, value)
+	assert.Nil(t, err)
+	rec := &LogRecord{Key: []byte("name"), Value: compressed, Type: LogRecordNormal, Compression: Snappy}
+	enc, _ := EncodeLogRecord(rec)
+	assert.Nil(t, dataFile.Write(enc))
+
+	// 壓縮算法保存在類型字節的高四位，不影響記錄的類型
+	header, _ := DecodeLogRecordHeader(enc)
+	assert.Equal(t, LogRecordNormal, header.Type())
+	assert.Equal(t, Snappy, header.Compression())
+
+	readRec, _, err := dataFile.ReadLogRecord(FileHeaderSize)
+	assert.Nil(t, err)
+	assert.Equal(t, rec, readRec)
+	decompressed, err := readRec.DecompressedValue()
+	assert.Nil(t, err)
+	assert.Equal(t, value, decompressed)
+}
@@ -10,24 +10,75 @@ import (
 )
 
 var (
-	ErrInValidCRC = errors.New("invalid crc value, log record may be corrupted")
+	ErrInValidCRC           = errors.New("invalid crc value, log record may be corrupted")
+	ErrUnknownCompression   = errors.New("unknown compression type")
+	ErrUnknownEncryptionKey = errors.New("the encryption key of the log record is unknown")
+	ErrDecryptFailed        = errors.New("failed to decrypt the log record, the key may be wrong")
+	ErrInvalidFileHeader    = errors.New("invalid data file header, the file may be corrupted")
+	ErrUnsupportedVersion   = errors.New("unsupported data file format version")
+	ErrUnsupportedChecksum  = errors.New("unsupported data file checksum type")
 )
 
-const FileNameSuffix = ".data"
+const (
+	FileNameSuffix        = ".data"
+	HintFileName          = "hint-index"
+	MergeFinishedFileName = "merge-finished"
+	SeqNoFileName         = "seq-no"
+)
 
 // DataFile 數據文件
 type DataFile struct {
 	FileId      uint32        // 文件 id
 	WriteOffset int64         // 文件寫到了哪個位置
 	IOManager   fio.IOManager // io 讀寫
+	Cipher      *Cipher       // 用於解密記錄以及加密 hint 記錄，為空時不加密
+	Header      *FileHeader   // 文件頭，只有數據文件才有
 }
 
 // OpenDataFile 打開新的數據文件
-func OpenDataFile(dirPath string, fileId uint32) (*DataFile, error) {
-	// 拼出有路徑的文件名
-	fileName := filepath.Join(dirPath, fmt.Sprintf("%09d", fileId)+FileNameSuffix)
+// 新建的數據文件會寫入文件頭，已有的數據文件會校驗文件頭，不支持的版本返回錯誤
+func OpenDataFile(dirPath string, fileId uint32, ioType fio.FileIOType) (*DataFile, error) {
+	fileName := GetDataFileName(dirPath, fileId)
+	// MMap 是只讀的，在打開之前先處理文件頭
+	header, err := initFileHeader(fileName)
+	if err != nil {
+		return nil, err
+	}
+	dataFile, err := newDataFile(fileName, fileId, ioType)
+	if err != nil {
+		return nil, err
+	}
+	dataFile.Header = header
+	dataFile.WriteOffset = header.Size()
+	return dataFile, nil
+}
+
+// OpenHintFile 打開 Hint 索引文件
+func OpenHintFile(dirPath string) (*DataFile, error) {
+	fileName := filepath.Join(dirPath, HintFileName)
+	return newDataFile(fileName, 0, fio.StandardFIO)
+}
+
+// OpenMergeFinishedFile 打開標識 merge 完成的文件
+func OpenMergeFinishedFile(dirPath string) (*DataFile, error) {
+	fileName := filepath.Join(dirPath, MergeFinishedFileName)
+	return newDataFile(fileName, 0, fio.StandardFIO)
+}
+
+// OpenSeqNoFile 打開存儲事務序列號的文件
+func OpenSeqNoFile(dirPath string) (*DataFile, error) {
+	fileName := filepath.Join(dirPath, SeqNoFileName)
+	return newDataFile(fileName, 0, fio.StandardFIO)
+}
+
+// GetDataFileName 拼出有路徑的數據文件名
+func GetDataFileName(dirPath string, fileId uint32) string {
+	return filepath.Join(dirPath, fmt.Sprintf("%09d", fileId)+FileNameSuffix)
+}
+
+func newDataFile(fileName string, fileId uint32, ioType fio.FileIOType) (*DataFile, error) {
 	// 初始化 IOManager 接口
-	ioManager, err := fio.NewIOManager(fileName)
+	ioManager, err := fio.NewIOManager(fileName, ioType)
 
 	if err != nil {
 		return nil, err
@@ -59,9 +110,13 @@ func (df *DataFile) ReadLogRecord(offset int64) (*LogRecord, int64, error) {
 
 	header, headerSize := DecodeLogRecordHeader(headerBuf)
 
-	// 表示讀取到了文件末尾，直接返回 EOF 錯誤
 	if header == nil {
-		return nil, 0, io.EOF
+		// 剩餘的數據不足一個完整的 header，表示讀取到了文件末尾，直接返回 EOF 錯誤
+		if headerBytes < maxHeaderSize {
+			return nil, 0, io.EOF
+		}
+		// 否則 header 中的長度信息是無效的，數據已經損壞
+		return nil, 0, ErrInValidCRC
 	}
 	// 表示讀取到了文件末尾，直接返回 EOF 錯誤
 	if header.crc == 0 && header.keySize == 0 && header.valueSize == 0 {
@@ -71,8 +126,12 @@ func (df *DataFile) ReadLogRecord(offset int64) (*LogRecord, int64, error) {
 	// 取出對應的 key 和 value 的長度
 	keySize, valueSize := int64(header.keySize), int64(header.valueSize)
 	var recordSize = headerSize + keySize + valueSize
+	// 記錄超出了文件的末尾，說明是不完整的記錄，不需要按照損壞的長度分配內存
+	if offset+recordSize > fileSize {
+		return nil, 0, io.EOF
+	}
 
-	record := &LogRecord{Type: header.recordType}
+	record := &LogRecord{Type: header.recordType, Expiry: header.expiry, Compression: header.compression}
 
 	// 開始讀取用戶實際存儲的 key-value 數據
 	if keySize > 0 || valueSize > 0 {
@@ -91,8 +150,27 @@ func (df *DataFile) ReadLogRecord(offset int64) (*LogRecord, int64, error) {
 		return nil, 0, ErrInValidCRC
 	}
 
+	// 解密 key 和 value
+	if header.encrypted {
+		if df.Cipher == nil {
+			return nil, 0, ErrUnknownEncryptionKey
+		}
+		if err := df.Cipher.decrypt(record); err != nil {
+			return nil, 0, err
+		}
+	}
+
 	return record, recordSize, nil
 }
+
+// HeaderSize 文件頭的大小，第一條記錄從這個位置開始存儲
+func (df *DataFile) HeaderSize() int64 {
+	if df.Header == nil {
+		return 0
+	}
+	return df.Header.Size()
+}
+
 func (df *DataFile) Sync() error {
 	return df.IOManager.Sync()
 }
@@ -101,6 +179,28 @@ func (df *DataFile) Close() error {
 	return df.IOManager.Close()
 }
 
+// Truncate 將數據文件截斷到指定的大小，並從截斷的位置繼續寫入
+func (df *DataFile) Truncate(size int64) error {
+	if err := df.IOManager.Truncate(size); err != nil {
+		return err
+	}
+	df.WriteOffset = size
+	return nil
+}
+
+// SetIOManager 切換數據文件的 IO 類型
+func (df *DataFile) SetIOManager(dirPath string, ioType fio.FileIOType) error {
+	if err := df.IOManager.Close(); err != nil {
+		return err
+	}
+	ioManager, err := fio.NewIOManager(GetDataFileName(dirPath, df.FileId), ioType)
+	if err != nil {
+		return err
+	}
+	df.IOManager = ioManager
+	return nil
+}
+
 func (df *DataFile) Write(buf []byte) error {
 	n, err := df.IOManager.Write(buf)
 	if err != nil {
@@ -110,6 +210,20 @@ func (df *DataFile) Write(buf []byte) error {
 	return nil
 }
 
+// WriteHintRecord 寫入索引信息到 hint 文件中，同時保存數據的過期時間
+func (df *DataFile) WriteHintRecord(key []byte, pos *LogRecordPos, expiry int64) error {
+	record := &LogRecord{
+		Key:    key,
+		Value:  EncodeLogRecordPos(pos),
+		Expiry: expiry,
+	}
+	encRecord, _, err := EncodeLogRecordWithCipher(record, df.Cipher)
+	if err != nil {
+		return err
+	}
+	return df.Write(encRecord)
+}
+
 func (df *DataFile) readNBytes(n int64, offset int64) (b []byte, err error) {
 	b = make([]byte, n)
 	_, err = df.IOManager.Read(b, offset)
@@ -1,19 +1,111 @@
 package data
 
 import (
+	"bitcask-go/fio"
+	"bytes"
+	"encoding/binary"
 	"github.com/stretchr/testify/assert"
+	"io"
+	"math"
 	"os"
 	"testing"
 )
 
 func TestOpenDataFile(t *testing.T) {
 	println(os.TempDir())
-	dataFile1, err := OpenDataFile(os.TempDir(), 0)
+	dataFile1, err := OpenDataFile(os.TempDir(), 0, fio.StandardFIO)
 	assert.Nil(t, err)
 	assert.NotNil(t, dataFile1)
 
-	dataFile2, err := OpenDataFile(os.TempDir(), 1111)
+	dataFile2, err := OpenDataFile(os.TempDir(), 1111, fio.StandardFIO)
 	assert.Nil(t, err)
 	assert.NotNil(t, dataFile2)
 
 }
+
+func TestDataFile_WriteHintRecord(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "bitcask-go-hint")
+	defer os.RemoveAll(dir)
+	hintFile, err := OpenHintFile(dir)
+	assert.Nil(t, err)
+	defer hintFile.Close()
+
+	pos := &LogRecordPos{Fid: 12, Offset: 3456}
+	err = hintFile.WriteHintRecord([]byte("name"), pos, 0)
+	assert.Nil(t, err)
+
+	record, _, err := hintFile.ReadLogRecord(0)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("name"), record.Key)
+	assert.Equal(t, pos, DecodeLogRecordPos(record.Value))
+}
+
+func TestDataFile_ReadLogRecord(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "bitcask-go-read")
+	defer os.RemoveAll(dir)
+	dataFile, err := OpenDataFile(dir, 0, fio.StandardFIO)
+	assert.Nil(t, err)
+	defer dataFile.Close()
+
+	rec1 := &LogRecord{Key: []byte("name"), Value: []byte("bitcask kv go")}
+	enc1, size1 := EncodeLogRecord(rec1)
+	err = dataFile.Write(enc1)
+	assert.Nil(t, err)
+
+	rec2 := &LogRecord{Key: []byte("session"), Value: []byte("token"), Expiry: 1700000000000000000}
+	enc2, size2 := EncodeLogRecord(rec2)
+	err = dataFile.Write(enc2)
+	assert.Nil(t, err)
+
+	readRec1, readSize1, err := dataFile.ReadLogRecord(FileHeaderSize)
+	assert.Nil(t, err)
+	assert.Equal(t, rec1, readRec1)
+	assert.Equal(t, size1, readSize1)
+
+	readRec2, readSize2, err := dataFile.ReadLogRecord(FileHeaderSize + size1)
+	assert.Nil(t, err)
+	assert.Equal(t, rec2, readRec2)
+	assert.Equal(t, size2, readSize2)
+	assert.True(t, readRec2.IsExpired(1700000000000000000))
+	assert.False(t, readRec2.IsExpired(1600000000000000000))
+}
+
+// 無效的長度信息不會導致崩潰，也不會按照損壞的長度分配內存
+func TestDataFile_ReadGarbage(t *testing.T) {
+	garbage := bytes.Repeat([]byte{0xff}, 16)
+	header, _ := DecodeLogRecordHeader(garbage)
+	assert.Nil(t, header)
+
+	// 負數的 key 長度
+	negative := []byte{0, 0, 0, 0, 0}
+	negative = binary.AppendVarint(negative, -10)
+	negative = binary.AppendVarint(negative, 0)
+	negative = binary.AppendVarint(negative, 0)
+	header, _ = DecodeLogRecordHeader(negative)
+	assert.Nil(t, header)
+
+	dir, _ := os.MkdirTemp("", "bitcask-go-garbage")
+	defer os.RemoveAll(dir)
+	dataFile, err := OpenDataFile(dir, 0, fio.StandardFIO)
+	assert.Nil(t, err)
+	defer dataFile.Close()
+
+	// 文件末尾不足一個 header 的無效數據視為文件結束
+	assert.Nil(t, dataFile.Write(garbage))
+	_, _, err = dataFile.ReadLogRecord(FileHeaderSize)
+	assert.Equal(t, io.EOF, err)
+
+	// 完整的 header 中長度信息無效說明數據已經損壞
+	assert.Nil(t, dataFile.Write(garbage))
+	_, _, err = dataFile.ReadLogRecord(FileHeaderSize)
+	assert.Equal(t, ErrInValidCRC, err)
+
+	// 長度超出文件末尾的記錄視為不完整的記錄
+	huge := []byte{1, 2, 3, 4, LogRecordNormal}
+	huge = binary.AppendVarint(huge, math.MaxUint32)
+	huge = binary.AppendVarint(huge, math.MaxUint32)
+	huge = binary.AppendVarint(huge, 0)
+	assert.Nil(t, dataFile.Write(huge))
+	_, _, err = dataFile.ReadLogRecord(FileHeaderSize + 32)
+	assert.Equal(t, io.EOF, err)
+}
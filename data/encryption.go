@@ -0,0 +1,115 @@
+package data
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+)
+
+// MaxEncryptionOverhead 加密之後一條記錄最多增加的長度
+// 包括 key id、GCM 標準的 12 字節 nonce 和 16 字節認證標籤、加密內容中 key 的長度，以及 header 中 value 長度的變長編碼增加的字節
+const MaxEncryptionOverhead = binary.MaxVarintLen32 + 12 + 16 + binary.MaxVarintLen32 + binary.MaxVarintLen32
+
+// Cipher 使用 AES-GCM 加密記錄中的 key 和 value
+// 加密後的記錄 header 中 key 的長度為 0，value 的格式為：
+//
+//	key id | nonce | 加密後的 (key 長度 | key | value) 以及認證標籤
+//
+// 記錄的類型、壓縮算法以及過期時間作為附加數據參與認證
+type Cipher struct {
+	aeads map[uint32]cipher.AEAD // 所有可用於解密的密鑰
+	keyId uint32                 // 加密時使用的密鑰 id，為 0 時不加密
+}
+
+// NewCipher 根據密鑰初始化 Cipher，密鑰的長度必須為 16、24 或 32 字節
+// keyId 指定加密時使用的密鑰，為 0 時只用於解密已經加密的數據
+func NewCipher(keys map[uint32][]byte, keyId uint32) (*Cipher, error) {
+	aeads := make(map[uint32]cipher.AEAD, len(keys))
+	for id, key := range keys {
+		if id == 0 {
+			return nil, errors.New("the encryption key id must be greater than 0")
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		aeads[id] = aead
+	}
+	if _, ok := aeads[keyId]; keyId != 0 && !ok {
+		return nil, ErrUnknownEncryptionKey
+	}
+	return &Cipher{aeads: aeads, keyId: keyId}, nil
+}
+
+// EncodeLogRecordWithCipher 對 LogRecord 進行編碼，cipher 不為空時使用當前的密鑰加密 key 和 value
+func EncodeLogRecordWithCipher(record *LogRecord, c *Cipher) ([]byte, int64, error) {
+	if c == nil || c.keyId == 0 {
+		encRecord, size := EncodeLogRecord(record)
+		return encRecord, size, nil
+	}
+
+	aead := c.aeads[c.keyId]
+	plaintext := make([]byte, binary.MaxVarintLen32+len(record.Key)+len(record.Value))
+	n := binary.PutUvarint(plaintext, uint64(len(record.Key)))
+	n += copy(plaintext[n:], record.Key)
+	n += copy(plaintext[n:], record.Value)
+
+	envelope := make([]byte, binary.MaxVarintLen32+aead.NonceSize(), binary.MaxVarintLen32+aead.NonceSize()+n+aead.Overhead())
+	index := binary.PutUvarint(envelope, uint64(c.keyId))
+	nonce := envelope[index : index+aead.NonceSize()]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, 0, err
+	}
+	envelope = aead.Seal(envelope[:index+aead.NonceSize()], nonce, plaintext[:n], additionalData(record))
+
+	encRecord, size := encodeLogRecord(&LogRecord{
+		Value:       envelope,
+		Type:        record.Type,
+		Expiry:      record.Expiry,
+		Compression: record.Compression,
+	}, encryptedFlag)
+	return encRecord, size, nil
+}
+
+// decrypt 解密記錄中的 key 和 value
+func (c *Cipher) decrypt(record *LogRecord) error {
+	keyId, n := binary.Uvarint(record.Value)
+	if n <= 0 {
+		return ErrDecryptFailed
+	}
+	aead, ok := c.aeads[uint32(keyId)]
+	if !ok {
+		return ErrUnknownEncryptionKey
+	}
+	if len(record.Value) < n+aead.NonceSize() {
+		return ErrDecryptFailed
+	}
+	nonce := record.Value[n : n+aead.NonceSize()]
+	plaintext, err := aead.Open(nil, nonce, record.Value[n+aead.NonceSize():], additionalData(record))
+	if err != nil {
+		return ErrDecryptFailed
+	}
+
+	keySize, n := binary.Uvarint(plaintext)
+	if n <= 0 || uint64(len(plaintext)-n) < keySize {
+		return ErrDecryptFailed
+	}
+	record.Key = plaintext[n : n+int(keySize)]
+	record.Value = plaintext[n+int(keySize):]
+	return nil
+}
+
+// additionalData 記錄中沒有被加密但需要認證的數據
+func additionalData(record *LogRecord) []byte {
+	buf := make([]byte, 2+binary.MaxVarintLen64)
+	buf[0] = record.Type
+	buf[1] = record.Compression
+	n := binary.PutVarint(buf[2:], record.Expiry)
+	return buf[:2+n]
+}
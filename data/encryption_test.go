@@ -0,0 +1,82 @@
+package data
+
+import (
+	"bitcask-go/fio"
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestNewCipher(t *testing.T) {
+	_, err := NewCipher(map[uint32][]byte{1: []byte("short key")}, 1)
+	assert.NotNil(t, err)
+	_, err = NewCipher(map[uint32][]byte{0: bytes.Repeat([]byte("k"), 16)}, 0)
+	assert.NotNil(t, err)
+	_, err = NewCipher(map[uint32][]byte{1: bytes.Repeat([]byte("k"), 16)}, 2)
+	assert.Equal(t, ErrUnknownEncryptionKey, err)
+
+	c, err := NewCipher(map[uint32][]byte{1: bytes.Repeat([]byte("k"), 32)}, 1)
+	assert.Nil(t, err)
+	assert.NotNil(t, c)
+}
+
+func TestDataFile_ReadEncryptedLogRecord(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "bitcask-go-encryption")
+	defer os.RemoveAll(dir)
+	dataFile, err := OpenDataFile(dir, 0, fio.StandardFIO)
+	assert.Nil(t, err)
+	defer dataFile.Close()
+
+	keys := map[uint32][]byte{
+		1: bytes.Repeat([]byte("a"), 16),
+		2: bytes.Repeat([]byte("b"), 32),
+	}
+	c1, err := NewCipher(keys, 1)
+	assert.Nil(t, err)
+	c2, err := NewCipher(keys, 2)
+	assert.Nil(t, err)
+
+	rec1 := &LogRecord{Key: []byte("secret-key"), Value: []byte("secret-value"), Expiry: 1700000000000000000}
+	enc1, size1, err := EncodeLogRecordWithCipher(rec1, c1)
+	assert.Nil(t, err)
+	assert.False(t, bytes.Contains(enc1, rec1.Key))
+	assert.False(t, bytes.Contains(enc1, rec1.Value))
+	assert.Nil(t, dataFile.Write(enc1))
+
+	rec2 := &LogRecord{Key: []byte("deleted-key"), Type: LogRecordDeleted}
+	enc2, size2, err := EncodeLogRecordWithCipher(rec2, c2)
+	assert.Nil(t, err)
+	assert.Nil(t, dataFile.Write(enc2))
+
+	header, _ := DecodeLogRecordHeader(enc1)
+	assert.True(t, header.Encrypted())
+	assert.Equal(t, uint32(0), header.KeySize())
+
+	// 沒有密鑰時無法讀取加密的記錄
+	_, _, err = dataFile.ReadLogRecord(FileHeaderSize)
+	assert.Equal(t, ErrUnknownEncryptionKey, err)
+
+	// 任意一個包含全部密鑰的 Cipher 都可以解密
+	dataFile.Cipher = c2
+	readRec1, readSize1, err := dataFile.ReadLogRecord(FileHeaderSize)
+	assert.Nil(t, err)
+	assert.Equal(t, size1, readSize1)
+	assert.Equal(t, rec1.Key, readRec1.Key)
+	assert.Equal(t, rec1.Value, readRec1.Value)
+	assert.Equal(t, rec1.Expiry, readRec1.Expiry)
+
+	readRec2, readSize2, err := dataFile.ReadLogRecord(FileHeaderSize + size1)
+	assert.Nil(t, err)
+	assert.Equal(t, size2, readSize2)
+	assert.Equal(t, rec2.Key, readRec2.Key)
+	assert.Equal(t, LogRecordDeleted, readRec2.Type)
+	assert.Equal(t, 0, len(readRec2.Value))
+
+	// 使用錯誤的密鑰解密失敗
+	wrong, err := NewCipher(map[uint32][]byte{1: bytes.Repeat([]byte("c"), 16)}, 1)
+	assert.Nil(t, err)
+	dataFile.Cipher = wrong
+	_, _, err = dataFile.ReadLogRecord(FileHeaderSize)
+	assert.Equal(t, ErrDecryptFailed, err)
+}
@@ -0,0 +1,126 @@
+package data
+
+import (
+	"bitcask-go/fio"
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+	"time"
+)
+
+// ChecksumType 記錄使用的校驗算法
+type ChecksumType = byte
+
+const (
+	ChecksumCRC32IEEE ChecksumType = iota + 1
+)
+
+const (
+	// LegacyFormatVersion 沒有文件頭的舊版本數據文件，記錄從文件的開頭存儲
+	LegacyFormatVersion uint16 = 0
+
+	// FormatVersion 當前的數據文件格式版本
+	FormatVersion uint16 = 1
+
+	// FileHeaderSize 文件頭的長度
+	// magic(4) | version(2) | checksum(1) | reserved(1) | createdAt(8) | reserved(12) | crc(4)
+	FileHeaderSize = 32
+)
+
+var fileMagic = []byte("BCSK")
+
+// FileHeader 數據文件頭，新建數據文件時寫入到文件的開頭
+type FileHeader struct {
+	Version   uint16       // 文件格式的版本
+	Checksum  ChecksumType // 記錄使用的校驗算法
+	CreatedAt int64        // 創建時間，Unix 納秒時間戳
+}
+
+// Size 文件頭在數據文件中佔據的大小，記錄從這個位置之後開始存儲
+func (h *FileHeader) Size() int64 {
+	if h.Version == LegacyFormatVersion {
+		return 0
+	}
+	return FileHeaderSize
+}
+
+// EncodeFileHeader 對文件頭進行編碼
+func EncodeFileHeader(h *FileHeader) []byte {
+	buf := make([]byte, FileHeaderSize)
+	copy(buf, fileMagic)
+	binary.LittleEndian.PutUint16(buf[4:], h.Version)
+	buf[6] = h.Checksum
+	binary.LittleEndian.PutUint64(buf[8:], uint64(h.CreatedAt))
+	crc := crc32.ChecksumIEEE(buf[:FileHeaderSize-crc32.Size])
+	binary.LittleEndian.PutUint32(buf[FileHeaderSize-crc32.Size:], crc)
+	return buf
+}
+
+// DecodeFileHeader 從數據文件開頭的數據中解碼文件頭
+// 不是以 magic 開頭的文件是沒有文件頭的舊版本數據文件
+func DecodeFileHeader(buf []byte) (*FileHeader, error) {
+	magicLen := min(len(buf), len(fileMagic))
+	if !bytes.Equal(buf[:magicLen], fileMagic[:magicLen]) {
+		return &FileHeader{Version: LegacyFormatVersion, Checksum: ChecksumCRC32IEEE}, nil
+	}
+	if len(buf) < FileHeaderSize {
+		return nil, ErrInvalidFileHeader
+	}
+	crc := binary.LittleEndian.Uint32(buf[FileHeaderSize-crc32.Size:])
+	if crc32.ChecksumIEEE(buf[:FileHeaderSize-crc32.Size]) != crc {
+		return nil, ErrInvalidFileHeader
+	}
+
+	header := &FileHeader{
+		Version:   binary.LittleEndian.Uint16(buf[4:]),
+		Checksum:  buf[6],
+		CreatedAt: int64(binary.LittleEndian.Uint64(buf[8:])),
+	}
+	if header.Version == LegacyFormatVersion || header.Version > FormatVersion {
+		return nil, ErrUnsupportedVersion
+	}
+	if header.Checksum != ChecksumCRC32IEEE {
+		return nil, ErrUnsupportedChecksum
+	}
+	return header, nil
+}
+
+// initFileHeader 讀取數據文件的文件頭，新建的數據文件寫入當前版本的文件頭
+// 寫入文件頭時崩潰留下的不完整文件頭會被重新寫入，其後不可能有記錄
+func initFileHeader(fileName string) (*FileHeader, error) {
+	file, err := os.OpenFile(fileName, os.O_CREATE|os.O_RDWR, fio.DataFilePerm)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	buf := make([]byte, FileHeaderSize)
+	n, err := file.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if n > 0 {
+		header, err := DecodeFileHeader(buf[:n])
+		if err != ErrInvalidFileHeader || n == FileHeaderSize {
+			return header, err
+		}
+		if err := file.Truncate(0); err != nil {
+			return nil, err
+		}
+	}
+
+	header := &FileHeader{
+		Version:   FormatVersion,
+		Checksum:  ChecksumCRC32IEEE,
+		CreatedAt: time.Now().UnixNano(),
+	}
+	if _, err := file.WriteAt(EncodeFileHeader(header), 0); err != nil {
+		return nil, err
+	}
+	if err := file.Sync(); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
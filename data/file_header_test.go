@@ -0,0 +1,93 @@
+package data
+
+import (
+	"bitcask-go/fio"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestEncodeFileHeader(t *testing.T) {
+	header := &FileHeader{Version: FormatVersion, Checksum: ChecksumCRC32IEEE, CreatedAt: 1700000000000000000}
+	buf := EncodeFileHeader(header)
+	assert.Equal(t, FileHeaderSize, len(buf))
+
+	decoded, err := DecodeFileHeader(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, header, decoded)
+	assert.Equal(t, int64(FileHeaderSize), decoded.Size())
+
+	// 不是以 magic 開頭的是舊版本的數據文件
+	record, _ := EncodeLogRecord(&LogRecord{Key: []byte("name"), Value: []byte("bitcask")})
+	decoded, err = DecodeFileHeader(record)
+	assert.Nil(t, err)
+	assert.Equal(t, LegacyFormatVersion, decoded.Version)
+	assert.Equal(t, int64(0), decoded.Size())
+
+	// 校驗值錯誤以及不完整的文件頭
+	corrupted := EncodeFileHeader(header)
+	corrupted[10] ^= 0xff
+	_, err = DecodeFileHeader(corrupted)
+	assert.Equal(t, ErrInvalidFileHeader, err)
+	_, err = DecodeFileHeader(buf[:10])
+	assert.Equal(t, ErrInvalidFileHeader, err)
+
+	// 不支持更新的版本以及未知的校驗算法
+	_, err = DecodeFileHeader(EncodeFileHeader(&FileHeader{Version: FormatVersion + 1, Checksum: ChecksumCRC32IEEE}))
+	assert.Equal(t, ErrUnsupportedVersion, err)
+	_, err = DecodeFileHeader(EncodeFileHeader(&FileHeader{Version: FormatVersion, Checksum: 10}))
+	assert.Equal(t, ErrUnsupportedChecksum, err)
+}
+
+func TestOpenDataFile_Header(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "bitcask-go-file-header")
+	defer os.RemoveAll(dir)
+
+	// 新建的數據文件寫入文件頭
+	dataFile, err := OpenDataFile(dir, 0, fio.StandardFIO)
+	assert.Nil(t, err)
+	assert.Equal(t, FormatVersion, dataFile.Header.Version)
+	assert.True(t, dataFile.Header.CreatedAt > 0)
+	assert.Equal(t, int64(FileHeaderSize), dataFile.WriteOffset)
+	size, err := dataFile.IOManager.Size()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(FileHeaderSize), size)
+	createdAt := dataFile.Header.CreatedAt
+	assert.Nil(t, dataFile.Close())
+
+	// 重新打開時讀取已有的文件頭，MMap 同樣可以打開新建的文件
+	dataFile, err = OpenDataFile(dir, 0, fio.MemoryMap)
+	assert.Nil(t, err)
+	assert.Equal(t, createdAt, dataFile.Header.CreatedAt)
+	assert.Nil(t, dataFile.Close())
+	dataFile, err = OpenDataFile(dir, 1, fio.MemoryMap)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(FileHeaderSize), dataFile.HeaderSize())
+	assert.Nil(t, dataFile.Close())
+
+	// 沒有文件頭的舊版本數據文件
+	record, size := EncodeLogRecord(&LogRecord{Key: []byte("name"), Value: []byte("bitcask")})
+	assert.Nil(t, os.WriteFile(GetDataFileName(dir, 2), record, 0644))
+	dataFile, err = OpenDataFile(dir, 2, fio.StandardFIO)
+	assert.Nil(t, err)
+	assert.Equal(t, LegacyFormatVersion, dataFile.Header.Version)
+	assert.Equal(t, int64(0), dataFile.HeaderSize())
+	readRecord, readSize, err := dataFile.ReadLogRecord(dataFile.HeaderSize())
+	assert.Nil(t, err)
+	assert.Equal(t, size, readSize)
+	assert.Equal(t, []byte("bitcask"), readRecord.Value)
+	assert.Nil(t, dataFile.Close())
+
+	// 寫入文件頭時崩潰留下的不完整文件頭會被重新寫入
+	assert.Nil(t, os.WriteFile(GetDataFileName(dir, 3), EncodeFileHeader(dataFile.Header)[:10], 0644))
+	dataFile, err = OpenDataFile(dir, 3, fio.StandardFIO)
+	assert.Nil(t, err)
+	assert.Equal(t, FormatVersion, dataFile.Header.Version)
+	assert.Nil(t, dataFile.Close())
+
+	// 不支持的版本
+	newer := EncodeFileHeader(&FileHeader{Version: FormatVersion + 1, Checksum: ChecksumCRC32IEEE})
+	assert.Nil(t, os.WriteFile(GetDataFileName(dir, 4), newer, 0644))
+	_, err = OpenDataFile(dir, 4, fio.StandardFIO)
+	assert.Equal(t, ErrUnsupportedVersion, err)
+}
@@ -3,6 +3,7 @@ package data
 import (
 	"encoding/binary"
 	"hash/crc32"
+	"math"
 )
 
 type LogRecordType = byte
@@ -10,46 +11,119 @@ type LogRecordType = byte
 const (
 	LogRecordNormal LogRecordType = iota
 	LogRecordDeleted
+	LogRecordTxnFinished
 )
 
-// CRC type keySize valueSize
-// 4 + 1  + 5   +   5        = 15
-const maxHeaderSize = binary.MaxVarintLen32*2 + 4 + 1
+// header 中的類型字節，低四位是記錄的類型，第 5 到 7 位是 value 的壓縮算法，最高位標識記錄是否加密
+const (
+	recordTypeMask   = 0x0f
+	compressionMask  = 0x07
+	compressionShift = 4
+	encryptedFlag    = 0x80
+)
+
+// CRC type keySize valueSize expiry
+// 4 + 1  + 5   +   5     +  10    = 25
+const maxHeaderSize = binary.MaxVarintLen32*2 + binary.MaxVarintLen64 + 4 + 1
 
 // LogRecord 寫入到數據文件的記錄
 // 因為數據文件中的數據是追加寫入的，類似日誌，所以命名為日誌
 type LogRecord struct {
-	Key   []byte
-	Value []byte
-	Type  LogRecordType
+	Key         []byte
+	Value       []byte
+	Type        LogRecordType
+	Expiry      int64           // 過期時間，Unix 納秒時間戳，0 表示永不過期
+	Compression CompressionType // Value 的壓縮算法，Value 中保存的是壓縮後的數據
 }
 
 // LogRecordHeader LogRecord 的頭部信息
 type LogRecordHeader struct {
-	crc        uint32        // crc 校驗值
-	recordType LogRecordType // 標識 LogRecord 的類型
-	keySize    uint32        // Key 的長度
-	valueSize  uint32        // Value 的長度
+	crc         uint32          // crc 校驗值
+	recordType  LogRecordType   // 標識 LogRecord 的類型
+	compression CompressionType // Value 的壓縮算法
+	encrypted   bool            // Key 和 Value 是否加密
+	keySize     uint32          // Key 的長度
+	valueSize   uint32          // Value 的長度
+	expiry      int64           // 過期時間
+}
+
+// TransactionRecord 暫存的事務相關的數據
+type TransactionRecord struct {
+	Record *LogRecord
+	Pos    *LogRecordPos
 }
 
 // LogRecordPos 數據內存索引，描述數據在磁盤上的位置
 type LogRecordPos struct {
 	Fid    uint32 // 文件 id，表示將數據存儲到了哪個文件中
 	Offset int64  // 偏移量，表示將數據存儲到了文件件哪個位置
+	Size   uint32 // 標識數據在磁盤上的大小
+	Expiry int64  // 數據的過期時間，0 表示永不過期，遍歷時不需要讀取記錄就能跳過過期的數據
+}
+
+// IsExpired 判斷位置指向的數據在給定的時間是否已經過期
+func (pos *LogRecordPos) IsExpired(now int64) bool {
+	return pos.Expiry > 0 && pos.Expiry <= now
+}
+
+// Crc 記錄中保存的 crc 校驗值
+func (h *LogRecordHeader) Crc() uint32 {
+	return h.crc
+}
+
+// Type 記錄的類型
+func (h *LogRecordHeader) Type() LogRecordType {
+	return h.recordType
+}
+
+// Compression Value 的壓縮算法
+func (h *LogRecordHeader) Compression() CompressionType {
+	return h.compression
+}
+
+// Encrypted Key 和 Value 是否加密
+func (h *LogRecordHeader) Encrypted() bool {
+	return h.encrypted
+}
+
+// KeySize Key 的長度
+func (h *LogRecordHeader) KeySize() uint32 {
+	return h.keySize
+}
+
+// ValueSize Value 的長度
+func (h *LogRecordHeader) ValueSize() uint32 {
+	return h.valueSize
+}
+
+// Expiry 過期時間，0 表示永不過期
+func (h *LogRecordHeader) Expiry() int64 {
+	return h.expiry
+}
+
+// IsExpired 判斷記錄在給定的時間是否已經過期
+func (lr *LogRecord) IsExpired(now int64) bool {
+	return lr.Expiry > 0 && lr.Expiry <= now
 }
 
 // EncodeLogRecord 對 LogRecord 進行編碼，返回字節數組及其長度
 func EncodeLogRecord(record *LogRecord) ([]byte, int64) {
+	return encodeLogRecord(record, 0)
+}
+
+// encodeLogRecord 對 LogRecord 進行編碼，flags 為類型字節中額外的標識位
+func encodeLogRecord(record *LogRecord, flags byte) ([]byte, int64) {
 	// 初始化一個 header 部分的字節數組
 	header := make([]byte, maxHeaderSize)
 
-	// 在第五個字節儲存 Type
-	header[4] = record.Type
+	// 在第五個字節儲存 Type 以及壓縮算法等標識
+	header[4] = record.Type | record.Compression<<compressionShift | flags
 	var index = 5
 	// 第五個字節後，存儲的是 key 和 value 的長度信息
 	// 使用變長類型，節省空間
 	index += binary.PutVarint(header[index:], int64(len(record.Key)))
 	index += binary.PutVarint(header[index:], int64(len(record.Value)))
+	index += binary.PutVarint(header[index:], record.Expiry)
 
 	var size = index + len(record.Key) + len(record.Value)
 
@@ -73,16 +147,19 @@ func EncodeLogRecord(record *LogRecord) ([]byte, int64) {
 }
 
 // DecodeLogRecordHeader 對字節數組中的 header 信息進行解碼
+// 數據不完整或者長度信息無效時返回 nil，例如崩潰時寫了一半的記錄或者損壞的數據
 func DecodeLogRecordHeader(buf []byte) (*LogRecordHeader, int64) {
 	if len(buf) <= 4 {
 		return nil, 0
 	}
 
 	header := &LogRecordHeader{
-		crc:        binary.LittleEndian.Uint32(buf[:4]),
-		recordType: buf[4],
-		keySize:    0,
-		valueSize:  0,
+		crc:         binary.LittleEndian.Uint32(buf[:4]),
+		recordType:  buf[4] & recordTypeMask,
+		compression: buf[4] >> compressionShift & compressionMask,
+		encrypted:   buf[4]&encryptedFlag != 0,
+		keySize:     0,
+		valueSize:   0,
 	}
 
 	// 第五個字節後，存儲的是 key 和 value 的長度信息
@@ -90,17 +167,66 @@ func DecodeLogRecordHeader(buf []byte) (*LogRecordHeader, int64) {
 
 	// 取出實際的 key size
 	keySize, n := binary.Varint(buf[index:])
+	if n <= 0 || keySize < 0 || keySize > math.MaxUint32 {
+		return nil, 0
+	}
 	header.keySize = uint32(keySize)
 	index += n
 
 	// 取出實際的 value size
 	valueSize, n := binary.Varint(buf[index:])
+	if n <= 0 || valueSize < 0 || valueSize > math.MaxUint32 {
+		return nil, 0
+	}
 	header.valueSize = uint32(valueSize)
 	index += n
 
+	// 取出過期時間
+	expiry, n := binary.Varint(buf[index:])
+	if n <= 0 {
+		return nil, 0
+	}
+	header.expiry = expiry
+	index += n
+
 	return header, int64(index)
 }
 
+// DecompressedValue 返回解壓之後的 Value
+func (lr *LogRecord) DecompressedValue() ([]byte, error) {
+	return DecompressValue(lr.Compression, lr.Value)
+}
+
+// EncodeLogRecordPos 對位置信息進行編碼
+func EncodeLogRecordPos(pos *LogRecordPos) []byte {
+	buf := make([]byte, binary.MaxVarintLen32*2+binary.MaxVarintLen64*2)
+	var index = 0
+	index += binary.PutVarint(buf[index:], int64(pos.Fid))
+	index += binary.PutVarint(buf[index:], pos.Offset)
+	index += binary.PutVarint(buf[index:], int64(pos.Size))
+	index += binary.PutVarint(buf[index:], pos.Expiry)
+	return buf[:index]
+}
+
+// DecodeLogRecordPos 解碼 LogRecordPos
+func DecodeLogRecordPos(buf []byte) *LogRecordPos {
+	var index = 0
+	fileId, n := binary.Varint(buf[index:])
+	index += n
+	offset, n := binary.Varint(buf[index:])
+	index += n
+	// 舊版本編碼的位置信息中沒有記錄大小以及過期時間
+	var size, expiry int64
+	if index < len(buf) {
+		size, n = binary.Varint(buf[index:])
+		index += n
+	}
+	if index < len(buf) {
+		expiry, _ = binary.Varint(buf[index:])
+	}
+	return &LogRecordPos{Fid: uint32(fileId), Offset: offset, Size: uint32(size), Expiry: expiry}
+}
+
 func getLogRecordCRC(record *LogRecord, header []byte) uint32 {
 	if record == nil {
 		return 0
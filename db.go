@@ -2,24 +2,48 @@ package bitcask_go
 
 import (
 	"bitcask-go/data"
+	"bitcask-go/fio"
 	"bitcask-go/index"
 	"errors"
+	"github.com/gofrs/flock"
 	"io"
 	"os"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
+)
+
+const (
+	fileLockName   = "flock"
+	seqNoKey       = "seq.no"
+	reclaimSizeKey = "reclaim.size"
 )
 
 // DB bitcask 數據引擎實例
 type DB struct {
-	options    Options                   // 用戶配置項
-	mu         *sync.RWMutex             // 讀寫互斥鎖
-	activeFile *data.DataFile            // 當前活躍數據文件，可以用於寫入
-	olderFiles map[uint32]*data.DataFile // 舊的數據文件，只讀
-	index      index.Indexer             // 內存索引
-	fileIds    []int                     // 文件 ID， 只能在加載索引時使用，其他情況禁止
+	options     Options                   // 用戶配置項
+	mu          *sync.RWMutex             // 讀寫互斥鎖
+	activeFile  *data.DataFile            // 當前活躍數據文件，可以用於寫入
+	olderFiles  map[uint32]*data.DataFile // 舊的數據文件，只讀
+	index       index.Indexer             // 內存索引
+	fileIds     []int                     // 文件 ID， 只能在加載索引時使用，其他情況禁止
+	seqNo       uint64                    // 序列號，每次寫入全局遞增
+	isMerging   bool                      // 是否正在 merge
+	mergeWg     sync.WaitGroup            // 正在進行的 merge，關閉時需要等待其完成
+	fileLock    *flock.Flock              // 文件鎖，保證多進程之間互斥
+	closed      bool                      // 是否已經關閉
+	snapshots   map[*Snapshot]struct{}    // 尚未釋放的快照
+	snapshotMu  *sync.Mutex               // 保護 snapshots
+	discarded   int64                     // 打開時從活躍文件末尾截斷的字節數
+	reclaimSize int64                     // 數據文件中已經失效、可以通過 merge 清理的字節數
+	mergedSize  int64                     // 已經被 merge 處理、等待下次打開時清理的無效字節數
+	mergeStop   chan struct{}             // 通知後台 merge 任務退出
+	mergeDone   chan struct{}             // 後台 merge 任務已經退出
+	stopOnce    sync.Once                 // 保證只通知一次後台任務退出
+	cipher      *data.Cipher              // 加密數據使用的 Cipher，沒有配置密鑰時為空
 }
 
 // Open 開啟數據庫
@@ -36,37 +60,159 @@ func Open(options Options) (*DB, error) {
 		}
 	}
 
+	// 判斷當前數據目錄是否正在使用
+	fileLock := flock.New(filepath.Join(options.DirPath, fileLockName))
+	hold, err := fileLock.TryLock()
+	if err != nil {
+		return nil, err
+	}
+	if !hold {
+		return nil, ErrDatabaseIsUsing
+	}
+
 	// 初始化 DB 實例結構體
 	db := &DB{
 		options:    options,
 		mu:         new(sync.RWMutex),
 		olderFiles: make(map[uint32]*data.DataFile),
-		index:      index.NewIndexer(options.indexType),
+		fileLock:   fileLock,
+		snapshots:  make(map[*Snapshot]struct{}),
+		snapshotMu: new(sync.Mutex),
+	}
+
+	// 打開失敗時需要釋放已經打開的文件以及文件鎖
+	opened := false
+	defer func() {
+		if opened {
+			return
+		}
+		if db.index != nil {
+			_ = db.index.Close()
+		}
+		_ = db.closeDataFiles()
+		_ = fileLock.Unlock()
+	}()
+
+	// 根據配置的密鑰初始化 Cipher
+	if len(options.EncryptionKeys) > 0 {
+		if db.cipher, err = data.NewCipher(options.EncryptionKeys, options.EncryptionKeyId); err != nil {
+			return nil, err
+		}
+	}
+
+	// 加載 merge 數據目錄
+	if err := db.loadMergeFiles(); err != nil {
+		return nil, err
+	}
+
+	// 使用其他類型的索引打開時，B+ 樹索引不會隨著寫入更新，刪除之後下次使用時重建
+	if options.IndexType != BPlusTree {
+		bptreeIndexFileName := filepath.Join(options.DirPath, index.BPlusTreeIndexFileName)
+		if err := os.Remove(bptreeIndexFileName); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	if db.index, err = index.NewIndexer(options.IndexType, options.DirPath, options.SyncWrites); err != nil {
+		return nil, err
 	}
 
 	// 加載對應的數據文件
 	if err := db.loadDataFiles(); err != nil {
 		return nil, err
 	}
-	// 從數據文件中加載索引
-	if err := db.loadDataFiles(); err != nil {
+
+	checkpoint, err := db.indexCheckpoint()
+	if err != nil {
 		return nil, err
 	}
+	if checkpoint != nil {
+		// B+ 樹索引保存在磁盤上，只需要加載上次保存的位置之後寫入的記錄
+		// 重複加載已經在索引中的記錄不影響結果，因此崩潰之後從較早的位置加載也是正確的
+		if err := db.loadIndexFromDataFiles(checkpoint); err != nil {
+			return nil, err
+		}
+		// 可清理的字節數需要根據完整的索引重新統計
+		db.reclaimSize = 0
+		if err := db.loadSeqNo(); err != nil {
+			return nil, err
+		}
+	} else {
+		// 從 hint 文件中加載索引
+		if err := db.loadIndexFromHintFile(); err != nil {
+			return nil, err
+		}
+
+		// 從數據文件中加載索引
+		if err := db.loadIndexFromDataFiles(nil); err != nil {
+			return nil, err
+		}
+	}
+	if err := db.saveIndexCheckpoint(); err != nil {
+		return nil, err
+	}
+
+	// 序列號文件只在正常關閉後的下一次打開時有效，避免異常退出時讀到過期的數據
+	seqNoFileName := filepath.Join(db.options.DirPath, data.SeqNoFileName)
+	if err := os.Remove(seqNoFileName); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	// 加載完成後重置 IO 類型為標準文件 IO
+	if db.options.MMapAtStartup {
+		if err := db.resetIoType(); err != nil {
+			return nil, err
+		}
+	}
+	// 啟動後台自動 merge 的任務
+	if db.options.MergeInterval > 0 {
+		db.mergeStop = make(chan struct{})
+		db.mergeDone = make(chan struct{})
+		go db.autoMerge()
+	}
+	opened = true
 	return db, nil
 }
 
 // Put 寫入 key-value 數據 (key 非空)
 func (db *DB) Put(key []byte, value []byte) error {
+	return db.putWithExpiry(key, value, 0)
+}
+
+// PutWithTTL 寫入 key-value 數據，數據在 ttl 時間之後過期
+func (db *DB) PutWithTTL(key []byte, value []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return ErrInvalidTTL
+	}
+	return db.putWithExpiry(key, value, time.Now().Add(ttl).UnixNano())
+}
+
+// PutWithExpiry 寫入 key-value 數據，數據在 expireAt 時間點過期
+func (db *DB) PutWithExpiry(key []byte, value []byte, expireAt time.Time) error {
+	if !expireAt.After(time.Now()) {
+		return ErrInvalidTTL
+	}
+	return db.putWithExpiry(key, value, expireAt.UnixNano())
+}
+
+func (db *DB) putWithExpiry(key []byte, value []byte, expiry int64) error {
 	// 判斷 key 是否有效
-	if len(key) == 0 {
-		return ErrKeyIsEmpty
+	if err := checkKey(key); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.closed {
+		return ErrDatabaseClosed
 	}
 
 	// 構造 LogRecord 結構體
+	db.seqNo++
 	record := &data.LogRecord{
-		Key:   key,
-		Value: value,
-		Type:  data.LogRecordNormal,
+		Key:    logRecordKeyWithSeq(key, db.seqNo, false),
+		Value:  value,
+		Type:   data.LogRecordNormal,
+		Expiry: expiry,
 	}
 
 	// 追加寫入到當前活躍數據文件中
@@ -75,9 +221,10 @@ func (db *DB) Put(key []byte, value []byte) error {
 		return err
 	}
 
-	// 更新內存索引
-	if ok := db.index.Put(key, pos); !ok {
-		return ErrIndexUpdateFailed
+	// 更新內存索引，被覆蓋的舊記錄成為無效數據
+	db.saveForSnapshots(key)
+	if oldPos := db.index.Put(key, pos); oldPos != nil {
+		db.reclaimSize += int64(oldPos.Size)
 	}
 	return nil
 }
@@ -92,6 +239,9 @@ func (db *DB) Get(key []byte) ([]byte, error) {
 	if len(key) == 0 {
 		return nil, ErrKeyIsEmpty
 	}
+	if db.closed {
+		return nil, ErrDatabaseClosed
+	}
 
 	// 從內存數據結構中取出 key 對應的索引信息
 	pos := db.index.Get(key)
@@ -100,6 +250,13 @@ func (db *DB) Get(key []byte) ([]byte, error) {
 		return nil, ErrKeyNotFound
 	}
 
+	// 從數據文件中獲取 value
+	return db.getValueByPosition(pos)
+}
+
+// getValueByPosition 根據索引信息獲取對應的 value
+// 在訪問此方法前必須持有讀鎖
+func (db *DB) getValueByPosition(pos *data.LogRecordPos) ([]byte, error) {
 	// 根據文件 id 找到對應的數據文件
 	var file *data.DataFile
 	if db.activeFile.FileId == pos.Fid {
@@ -118,40 +275,191 @@ func (db *DB) Get(key []byte) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	if record.Type == data.LogRecordDeleted {
+	// 數據已經被刪除或者過期
+	if record.Type == data.LogRecordDeleted || record.IsExpired(time.Now().UnixNano()) {
 		return nil, ErrKeyNotFound
 	}
-	return record.Value, nil
+	return record.DecompressedValue()
 }
 
 func (db *DB) Delete(key []byte) error {
-	if len(key) == 0 {
-		return ErrKeyIsEmpty
+	if err := checkKey(key); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.closed {
+		return ErrDatabaseClosed
 	}
+
 	// 先檢查 key 是否存在，如果不存在直接返回
 	if pos := db.index.Get(key); pos == nil {
 		return nil
 	}
 	// 構造 LogRecord，標示其是被刪除的
-	record := &data.LogRecord{Key: key, Type: data.LogRecordDeleted}
+	db.seqNo++
+	record := &data.LogRecord{
+		Key:  logRecordKeyWithSeq(key, db.seqNo, false),
+		Type: data.LogRecordDeleted,
+	}
 	// 寫入到數據文件中
-	_, err := db.appendLogRecord(record)
+	pos, err := db.appendLogRecord(record)
 	if err != nil {
 		return err
 	}
+	// 刪除標記本身也是可以被清理的數據
+	db.reclaimSize += int64(pos.Size)
 
-	ok := db.index.Delete(key)
-	if !ok {
-		return ErrIndexUpdateFailed
+	// 從內存索引中將對應的 key 刪除，舊記錄成為無效數據
+	db.saveForSnapshots(key)
+	if oldPos, _ := db.index.Delete(key); oldPos != nil {
+		db.reclaimSize += int64(oldPos.Size)
 	}
 	return nil
 }
 
-// appendLogRecord 追加寫數據到活躍文件中
-func (db *DB) appendLogRecord(record *data.LogRecord) (*data.LogRecordPos, error) {
+// Close 關閉數據庫
+func (db *DB) Close() error {
+	// 先等待後台的 merge 任務退出，merge 過程中需要獲取鎖
+	db.stopAutoMerge()
+
+	db.mu.Lock()
+	if db.closed {
+		db.mu.Unlock()
+		return ErrDatabaseClosed
+	}
+	db.closed = true
+	db.mu.Unlock()
+
+	// 標記關閉之後不會再有新的 merge，等待正在進行的 merge 完成，merge 過程中會讀取數據文件
+	db.mergeWg.Wait()
+
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
+	defer func() {
+		// 釋放文件鎖
+		_ = db.fileLock.Unlock()
+	}()
+
+	// 釋放所有的快照，再關閉索引
+	db.snapshotMu.Lock()
+	for snapshot := range db.snapshots {
+		snapshot.clear()
+		delete(db.snapshots, snapshot)
+	}
+	db.snapshotMu.Unlock()
+
+	// 先持久化數據文件，再保存索引的加載位置
+	if db.activeFile != nil {
+		if err := db.activeFile.Sync(); err != nil {
+			return err
+		}
+	}
+	if err := db.saveIndexCheckpoint(); err != nil {
+		return err
+	}
+	if err := db.index.Close(); err != nil {
+		return err
+	}
+
+	// 保存當前事務序列號，下次打開時不需要從數據文件中恢復
+	seqNoFile, err := data.OpenSeqNoFile(db.options.DirPath)
+	if err != nil {
+		return err
+	}
+	// 同時保存可清理的字節數，B+ 樹索引下次打開時無法通過加載索引重新統計
+	for _, record := range []*data.LogRecord{
+		{Key: []byte(seqNoKey), Value: []byte(strconv.FormatUint(db.seqNo, 10))},
+		{Key: []byte(reclaimSizeKey), Value: []byte(strconv.FormatInt(db.reclaimSize, 10))},
+	} {
+		encRecord, _ := data.EncodeLogRecord(record)
+		if err := seqNoFile.Write(encRecord); err != nil {
+			return err
+		}
+	}
+	if err := seqNoFile.Sync(); err != nil {
+		return err
+	}
+	if err := seqNoFile.Close(); err != nil {
+		return err
+	}
+
+	return db.closeDataFiles()
+}
+
+// Sync 持久化當前活躍數據文件，B+ 樹索引同時保存加載位置，崩潰後只需要從這個位置重新加載
+func (db *DB) Sync() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.closed {
+		return ErrDatabaseClosed
+	}
+	if db.activeFile == nil {
+		return nil
+	}
+	if err := db.activeFile.Sync(); err != nil {
+		return err
+	}
+	return db.saveIndexCheckpoint()
+}
+
+// indexCheckpoint 返回 B+ 樹索引已經包含的數據位置，其他類型的索引或者沒有保存過時返回 nil
+// 位置已經超出了數據文件的範圍時，說明索引和數據文件不一致，刪除索引之後重建
+func (db *DB) indexCheckpoint() (*data.LogRecordPos, error) {
+	bptree, ok := db.index.(*index.BPlusTree)
+	if !ok {
+		return nil, nil
+	}
+	checkpoint := bptree.Checkpoint()
+	if checkpoint == nil {
+		return nil, nil
+	}
+
+	var file *data.DataFile
+	if db.activeFile != nil && db.activeFile.FileId == checkpoint.Fid {
+		file = db.activeFile
+	} else {
+		file = db.olderFiles[checkpoint.Fid]
+	}
+	if file != nil {
+		size, err := file.IOManager.Size()
+		if err != nil {
+			return nil, err
+		}
+		if checkpoint.Offset <= size {
+			return checkpoint, nil
+		}
+	}
+
+	if err := db.index.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(filepath.Join(db.options.DirPath, index.BPlusTreeIndexFileName)); err != nil {
+		return nil, err
+	}
+	indexer, err := index.NewIndexer(db.options.IndexType, db.options.DirPath, db.options.SyncWrites)
+	if err != nil {
+		return nil, err
+	}
+	db.index = indexer
+	return nil, nil
+}
+
+// saveIndexCheckpoint 將活躍文件當前的寫入位置保存到 B+ 樹索引中
+// 在訪問此方法前必須持有互斥鎖，保證之前的寫入都已經更新到了索引中
+func (db *DB) saveIndexCheckpoint() error {
+	bptree, ok := db.index.(*index.BPlusTree)
+	if !ok || db.activeFile == nil {
+		return nil
+	}
+	return bptree.SetCheckpoint(&data.LogRecordPos{Fid: db.activeFile.FileId, Offset: db.activeFile.WriteOffset})
+}
+
+// appendLogRecord 追加寫數據到活躍文件中
+// 在訪問此方法前必須持有互斥鎖
+func (db *DB) appendLogRecord(record *data.LogRecord) (*data.LogRecordPos, error) {
 	// 判斷當前活躍數據文件是否存在
 	// 如果為空，則初始化數據文件
 	if db.activeFile == nil {
@@ -160,8 +468,16 @@ func (db *DB) appendLogRecord(record *data.LogRecord) (*data.LogRecordPos, error
 		}
 	}
 
-	// 寫入數據編碼
-	encodedRecord, size := data.EncodeLogRecord(record)
+	// 根據配置壓縮 value
+	if err := db.compressRecord(record); err != nil {
+		return nil, err
+	}
+
+	// 寫入數據編碼，根據配置加密 key 和 value
+	encodedRecord, size, err := data.EncodeLogRecordWithCipher(record, db.cipher)
+	if err != nil {
+		return nil, err
+	}
 
 	// 如果寫入的數據已經到達了活躍文件大小的閾值，則關閉活躍文件，並打開新的文件
 	if db.activeFile.WriteOffset+size > db.options.DataFileSize {
@@ -196,10 +512,30 @@ func (db *DB) appendLogRecord(record *data.LogRecord) (*data.LogRecordPos, error
 	pos := &data.LogRecordPos{
 		Fid:    db.activeFile.FileId,
 		Offset: offset,
+		Size:   uint32(size),
+		Expiry: record.Expiry,
 	}
 	return pos, nil
 }
 
+// compressRecord 使用配置的算法壓縮記錄中的 value
+// 已經壓縮過的記錄以及壓縮後沒有變小的 value 按原樣保存
+func (db *DB) compressRecord(record *data.LogRecord) error {
+	if db.options.Compression == data.NoCompression || record.Compression != data.NoCompression ||
+		record.Type != data.LogRecordNormal || len(record.Value) == 0 {
+		return nil
+	}
+	compressed, err := data.CompressValue(db.options.Compression, record.Value)
+	if err != nil {
+		return err
+	}
+	if len(compressed) < len(record.Value) {
+		record.Value = compressed
+		record.Compression = db.options.Compression
+	}
+	return nil
+}
+
 // setActiveDataFile 設置當前活躍文件
 // 在訪問此方法前必須持有互斥鎖
 func (db *DB) setActiveDataFile() error {
@@ -207,12 +543,18 @@ func (db *DB) setActiveDataFile() error {
 	if db.activeFile != nil {
 		initialFileId = db.activeFile.FileId + 1
 	}
+	return db.openActiveDataFile(initialFileId)
+}
 
+// openActiveDataFile 打開指定 id 的數據文件作為活躍文件
+// 在訪問此方法前必須持有鎖
+func (db *DB) openActiveDataFile(fileId uint32) error {
 	// 打開新的數據文件
-	dataFile, err := data.OpenDataFile(db.options.DirPath, initialFileId)
+	dataFile, err := data.OpenDataFile(db.options.DirPath, fileId, fio.StandardFIO)
 	if err != nil {
 		return err
 	}
+	dataFile.Cipher = db.cipher
 
 	db.activeFile = dataFile
 
@@ -245,12 +587,19 @@ func (db *DB) loadDataFiles() error {
 
 	db.fileIds = fileIds
 
+	// 啟動時可以使用 MMap 加速數據的讀取
+	ioType := fio.StandardFIO
+	if db.options.MMapAtStartup {
+		ioType = fio.MemoryMap
+	}
+
 	// 遍歷每個文件 ID， 打開對應檔數據文件
 	for i, fid := range fileIds {
-		file, err := data.OpenDataFile(db.options.DirPath, uint32(fid))
+		file, err := data.OpenDataFile(db.options.DirPath, uint32(fid), ioType)
 		if err != nil {
 			return err
 		}
+		file.Cipher = db.cipher
 
 		if i == len(fileIds)-1 {
 			// 最後一個， ID 是最大檔，說明是當前活躍文件
@@ -264,25 +613,80 @@ func (db *DB) loadDataFiles() error {
 }
 
 // loadIndexFromDataFiles 從數據文件中加載索引
-// 遍歷文件中所有的記錄，並更新到內存索引中
-func (db *DB) loadIndexFromDataFiles() error {
+// 遍歷文件中所有的記錄，並更新到內存索引中，start 不為空時只加載這個位置之後的記錄
+func (db *DB) loadIndexFromDataFiles(start *data.LogRecordPos) error {
 	// 沒有文件，說明數據庫是空的，直接返回
 	if len(db.fileIds) == 0 {
 		return nil
 	}
+	// 查看是否發生過 merge，已經 merge 過的文件索引從 hint 文件中加載
+	hasMerge, nonMergeFileId, mergeSeqNo := false, uint32(0), uint64(0)
+	mergeFinFileName := filepath.Join(db.options.DirPath, data.MergeFinishedFileName)
+	hintFileName := filepath.Join(db.options.DirPath, data.HintFileName)
+	if _, err := os.Stat(mergeFinFileName); err == nil && start == nil {
+		if _, err := os.Stat(hintFileName); err == nil {
+			fid, seqNo, err := db.readMergeFinishedFile(db.options.DirPath)
+			if err != nil {
+				return err
+			}
+			hasMerge = true
+			nonMergeFileId = fid
+			mergeSeqNo = seqNo
+		}
+	}
+
+	now := time.Now().UnixNano()
+	updateIndex := func(record *data.LogRecord, pos *data.LogRecordPos) {
+		var oldPos *data.LogRecordPos
+		// 已經過期的數據和被刪除的數據一樣處理
+		if record.Type == data.LogRecordDeleted || record.IsExpired(now) {
+			// merge 之後 key 對應的舊記錄可能已經被清理，刪除失敗可以忽略
+			oldPos, _ = db.index.Delete(record.Key)
+			db.reclaimSize += int64(pos.Size)
+		} else {
+			oldPos = db.index.Put(record.Key, pos)
+		}
+		if oldPos != nil {
+			db.reclaimSize += int64(oldPos.Size)
+		}
+	}
+
+	// 暫存事務數據，只有讀到事務完成的標識後才更新索引
+	transactionRecords := make(map[uint64][]*data.TransactionRecord)
+	// hint 文件中沒有保存序列號，已經 merge 過的記錄的序列號從 merge 完成的標識中恢復
+	var currentSeqNo = mergeSeqNo
+
 	// 遍歷所有的文件 ID，處理文件中的記錄
 	for i, fid := range db.fileIds {
 		var fileId = uint32(fid)
+		// 如果比最近未參與 merge 的文件 id 更小，則說明已經從 hint 文件中加載索引了
+		if hasMerge && fileId < nonMergeFileId {
+			continue
+		}
+		if start != nil && fileId < start.Fid {
+			continue
+		}
 		var file *data.DataFile
 		if fileId == db.activeFile.FileId {
 			file = db.activeFile
 		} else {
 			file = db.olderFiles[fileId]
 		}
-		var offset int64 = 0
+		// 記錄從文件頭之後開始存儲
+		offset := file.HeaderSize()
+		if start != nil && fileId == start.Fid && start.Offset > offset {
+			offset = start.Offset
+		}
 		for {
 			record, size, err := file.ReadLogRecord(offset)
 			if err != nil {
+				// 活躍文件末尾可能有崩潰時寫了一半的記錄，需要截斷
+				if i == len(db.fileIds)-1 {
+					if err := db.truncateTornTail(offset, err); err != nil {
+						return err
+					}
+					break
+				}
 				if err == io.EOF {
 					break
 				}
@@ -293,24 +697,217 @@ func (db *DB) loadIndexFromDataFiles() error {
 			pos := &data.LogRecordPos{
 				Fid:    fileId,
 				Offset: offset,
+				Size:   uint32(size),
+				Expiry: record.Expiry,
 			}
-			var ok bool
-			if record.Type == data.LogRecordDeleted {
-				ok = db.index.Delete(record.Key)
+
+			// 解析 key，拿到事務序列號
+			realKey, seqNo, inTxn := ParseLogRecordKey(record.Key)
+			record.Key = realKey
+			if !inTxn {
+				// 非事務操作，直接更新內存索引
+				updateIndex(record, pos)
 			} else {
-				ok = db.index.Put(record.Key, pos)
+				// 事務完成，對應的 seqNo 的數據可以更新到內存索引中
+				if record.Type == data.LogRecordTxnFinished {
+					for _, txnRecord := range transactionRecords[seqNo] {
+						updateIndex(txnRecord.Record, txnRecord.Pos)
+					}
+					delete(transactionRecords, seqNo)
+					db.reclaimSize += size
+				} else {
+					transactionRecords[seqNo] = append(transactionRecords[seqNo], &data.TransactionRecord{
+						Record: record,
+						Pos:    pos,
+					})
+				}
 			}
-			if !ok {
-				return ErrIndexUpdateFailed
+
+			// 更新事務序列號
+			if seqNo > currentSeqNo {
+				currentSeqNo = seqNo
 			}
+
 			// 遞增 offset，下一次從新的位置讀取
 			offset += size
 		}
-		// 如果是最後一個文件，即當前活躍文件，更新這個文件的 WriteOffset
-		if i == len(db.fileIds)-1 {
-			db.activeFile.WriteOffset = offset
+	}
+
+	// 沒有完成的事務數據不會被加載，同樣屬於無效數據
+	for _, txnRecords := range transactionRecords {
+		for _, txnRecord := range txnRecords {
+			db.reclaimSize += int64(txnRecord.Pos.Size)
 		}
 	}
+
+	// 更新事務序列號
+	db.seqNo = currentSeqNo
+	return nil
+}
+
+// truncateTornTail 處理活躍文件末尾寫了一半的記錄
+// offset 是活躍文件中最後一條有效記錄的結束位置，readErr 是從這個位置讀取記錄時返回的錯誤
+// 如果之後的數據中已經沒有有效的記錄，說明是崩潰時不完整的寫入，截斷之後從 offset 處繼續寫入
+// 否則說明文件中間的數據損壞了，需要使用 Repair 進行修復
+func (db *DB) truncateTornTail(offset int64, readErr error) error {
+	// 密鑰錯誤導致的讀取失敗不是不完整的寫入，不能截斷
+	if errors.Is(readErr, data.ErrUnknownEncryptionKey) || errors.Is(readErr, data.ErrDecryptFailed) {
+		return readErr
+	}
+	size, err := db.activeFile.IOManager.Size()
+	if err != nil {
+		return err
+	}
+	if offset >= size {
+		db.activeFile.WriteOffset = offset
+		return nil
+	}
+
+	tail := make([]byte, size-offset)
+	if _, err := db.activeFile.IOManager.Read(tail, offset); err != nil {
+		return err
+	}
+	ranges := findCorruptRanges(db.activeFile.FileId, tail, 0)
+	if len(ranges) > 1 || (len(ranges) == 1 && !ranges[0].Torn) {
+		if readErr == io.EOF {
+			return ErrDataDirectoryCorrupted
+		}
+		return readErr
+	}
+
+	if err := db.activeFile.Truncate(offset); err != nil {
+		return err
+	}
+	db.discarded += size - offset
+	return nil
+}
+
+// DiscardedBytes 打開數據庫時從活躍文件末尾截斷的不完整寫入的字節數
+func (db *DB) DiscardedBytes() int64 {
+	return db.discarded
+}
+
+// resetIoType 將數據文件的 IO 類型設置為標準文件 IO
+func (db *DB) resetIoType() error {
+	if db.activeFile == nil {
+		return nil
+	}
+	if err := db.activeFile.SetIOManager(db.options.DirPath, fio.StandardFIO); err != nil {
+		return err
+	}
+	for _, dataFile := range db.olderFiles {
+		if err := dataFile.SetIOManager(db.options.DirPath, fio.StandardFIO); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// closeDataFiles 關閉所有打開的數據文件
+func (db *DB) closeDataFiles() error {
+	if db.activeFile != nil {
+		if err := db.activeFile.Close(); err != nil {
+			return err
+		}
+	}
+	for _, file := range db.olderFiles {
+		if err := file.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadSeqNo 在不加載索引的情況下恢復最新的事務序列號以及可清理的字節數
+// 數據庫正常關閉時會保存序列號，否則需要從數據文件中讀取
+func (db *DB) loadSeqNo() error {
+	seqNoFileName := filepath.Join(db.options.DirPath, data.SeqNoFileName)
+	if _, err := os.Stat(seqNoFileName); err == nil {
+		loaded, err := db.loadSeqNoFile()
+		if err != nil || loaded {
+			return err
+		}
+	}
+
+	for _, fid := range db.fileIds {
+		var file *data.DataFile
+		if uint32(fid) == db.activeFile.FileId {
+			file = db.activeFile
+		} else {
+			file = db.olderFiles[uint32(fid)]
+		}
+		offset := file.HeaderSize()
+		for {
+			record, size, err := file.ReadLogRecord(offset)
+			if err != nil {
+				if file == db.activeFile {
+					if err := db.truncateTornTail(offset, err); err != nil {
+						return err
+					}
+					break
+				}
+				if err == io.EOF {
+					break
+				}
+				return err
+			}
+			realKey, seqNo, _ := ParseLogRecordKey(record.Key)
+			if seqNo > db.seqNo {
+				db.seqNo = seqNo
+			}
+			// 和索引中的位置不一致的記錄都是無效數據
+			if record.Type != data.LogRecordNormal {
+				db.reclaimSize += size
+			} else if pos := db.index.Get(realKey); pos == nil || pos.Fid != file.FileId || pos.Offset != offset {
+				db.reclaimSize += size
+			}
+			offset += size
+		}
+	}
+	return nil
+}
+
+// loadSeqNoFile 從序列號文件中讀取事務序列號以及可清理的字節數
+// 舊版本的序列號文件中沒有保存可清理的字節數，此時返回 false，需要從數據文件中重新統計
+func (db *DB) loadSeqNoFile() (bool, error) {
+	seqNoFile, err := data.OpenSeqNoFile(db.options.DirPath)
+	if err != nil {
+		return false, err
+	}
+	defer seqNoFile.Close()
+	record, size, err := seqNoFile.ReadLogRecord(0)
+	if err != nil {
+		return false, err
+	}
+	seqNo, err := strconv.ParseUint(string(record.Value), 10, 64)
+	if err != nil {
+		return false, err
+	}
+
+	record, _, err = seqNoFile.ReadLogRecord(size)
+	if err == io.EOF {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	reclaimSize, err := strconv.ParseInt(string(record.Value), 10, 64)
+	if err != nil {
+		return false, err
+	}
+	db.seqNo = seqNo
+	db.reclaimSize = reclaimSize
+	return true, nil
+}
+
+// checkKey 校驗寫入的 key，key 不能為空，也不能超過索引支持的最大長度
+func checkKey(key []byte) error {
+	if len(key) == 0 {
+		return ErrKeyIsEmpty
+	}
+	if len(key) > index.MaxKeySize {
+		return ErrKeyTooLarge
+	}
 	return nil
 }
 
@@ -318,8 +915,20 @@ func checkOptions(options Options) error {
 	if options.DirPath == "" {
 		return errors.New("database directory path is invalid")
 	}
-	if options.DataFileSize == 0 {
+	if options.DataFileSize <= 0 {
 		return errors.New("data file size must be greater than 0")
 	}
+	if options.MergeInterval < 0 {
+		return errors.New("merge interval must not be negative")
+	}
+	if options.MergeRatio < 0 || options.MergeRatio > 1 {
+		return errors.New("invalid merge ratio, must between 0 and 1")
+	}
+	if options.Compression > data.Zstd {
+		return errors.New("unknown compression type")
+	}
+	if _, ok := options.EncryptionKeys[options.EncryptionKeyId]; options.EncryptionKeyId != 0 && !ok {
+		return errors.New("the encryption key id is not found in the encryption keys")
+	}
 	return nil
 }
@@ -0,0 +1,649 @@
+package bitcask_go
+
+import (
+	"bitcask-go/data"
+	"bitcask-go/index"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 測試完成之後銷毀 DB 數據目錄
+func destroyDB(db *DB) {
+	if db != nil {
+		_ = db.Close()
+		err := os.RemoveAll(db.options.DirPath)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+func getTestKey(i int) []byte {
+	return []byte(fmt.Sprintf("bitcask-go-key-%09d", i))
+}
+
+func TestOpen(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-open")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+	assert.NotNil(t, db)
+}
+
+func TestOpen_DatabaseIsUsing(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-flock")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	// 數據目錄已經被打開
+	_, err = Open(opts)
+	assert.Equal(t, ErrDatabaseIsUsing, err)
+
+	// 釋放之後可以重新打開
+	err = db.Close()
+	assert.Nil(t, err)
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	assert.NotNil(t, db2)
+	err = db2.Close()
+	assert.Nil(t, err)
+}
+
+func TestDB_PutGetDelete(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-put")
+	opts.DirPath = dir
+	opts.DataFileSize = 64 * 1024
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	// 寫入足夠多的數據，觸發數據文件的切換
+	for i := 0; i < 2000; i++ {
+		err := db.Put(getTestKey(i), []byte(fmt.Sprintf("value-%d", i)))
+		assert.Nil(t, err)
+	}
+	assert.NotEqual(t, 0, len(db.olderFiles))
+
+	val, err := db.Get(getTestKey(10))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value-10"), val)
+
+	// 重複 Put 覆蓋舊值
+	err = db.Put(getTestKey(10), []byte("new-value"))
+	assert.Nil(t, err)
+	val, err = db.Get(getTestKey(10))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("new-value"), val)
+
+	err = db.Delete(getTestKey(11))
+	assert.Nil(t, err)
+	_, err = db.Get(getTestKey(11))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	err = db.Put(nil, []byte("value"))
+	assert.Equal(t, ErrKeyIsEmpty, err)
+
+	// 重新打開數據庫，從數據文件中恢復索引
+	err = db.Close()
+	assert.Nil(t, err)
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	val, err = db2.Get(getTestKey(10))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("new-value"), val)
+	_, err = db2.Get(getTestKey(11))
+	assert.Equal(t, ErrKeyNotFound, err)
+	val, err = db2.Get(getTestKey(1999))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value-1999"), val)
+}
+
+func TestDB_ARTIndex(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-art")
+	opts.DirPath = dir
+	opts.IndexType = ART
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	for i := 0; i < 100; i++ {
+		err := db.Put(getTestKey(i), []byte(fmt.Sprintf("value-%d", i)))
+		assert.Nil(t, err)
+	}
+	err = db.Delete(getTestKey(5))
+	assert.Nil(t, err)
+
+	err = db.Close()
+	assert.Nil(t, err)
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	val, err := db2.Get(getTestKey(20))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value-20"), val)
+	_, err = db2.Get(getTestKey(5))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	iter := db2.NewIterator(IteratorOptions{Prefix: []byte("bitcask-go-key-00000001")})
+	var keys int
+	for ; iter.Valid(); iter.Next() {
+		keys++
+	}
+	iter.Close()
+	assert.Equal(t, 10, keys)
+	err = db2.Close()
+	assert.Nil(t, err)
+}
+
+func TestDB_BPlusTreeIndex(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-bptree")
+	opts.DirPath = dir
+	opts.IndexType = BPlusTree
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	for i := 0; i < 100; i++ {
+		err := db.Put(getTestKey(i), []byte(fmt.Sprintf("value-%d", i)))
+		assert.Nil(t, err)
+	}
+	err = db.Delete(getTestKey(5))
+	assert.Nil(t, err)
+	wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+	_ = wb.Put(getTestKey(200), []byte("value-200"))
+	err = wb.Commit()
+	assert.Nil(t, err)
+
+	// 重啟之後直接使用磁盤上的索引
+	err = db.Close()
+	assert.Nil(t, err)
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(102), db2.seqNo)
+	val, err := db2.Get(getTestKey(20))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value-20"), val)
+	_, err = db2.Get(getTestKey(5))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	// 重啟後繼續寫入，寫入位置需要正確
+	err = db2.Put(getTestKey(300), []byte("value-300"))
+	assert.Nil(t, err)
+	val, err = db2.Get(getTestKey(300))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value-300"), val)
+
+	// merge 之後索引文件被刪除並重新構建
+	err = db2.Merge()
+	assert.Nil(t, err)
+	err = db2.Close()
+	assert.Nil(t, err)
+	db3, err := Open(opts)
+	assert.Nil(t, err)
+	val, err = db3.Get(getTestKey(200))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value-200"), val)
+	_, err = db3.Get(getTestKey(5))
+	assert.Equal(t, ErrKeyNotFound, err)
+	err = db3.Close()
+	assert.Nil(t, err)
+}
+
+// B+ 樹索引的錯誤通過返回值報告，不會導致進程崩潰
+func TestDB_BPlusTreeIndexErrors(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-bptree-errors")
+	opts.DirPath = dir
+	opts.IndexType = BPlusTree
+	db, err := Open(opts)
+	assert.Nil(t, err)
+
+	// 超過長度限制的 key 在寫入數據文件之前被拒絕
+	largeKey := make([]byte, index.MaxKeySize+1)
+	assert.Equal(t, ErrKeyTooLarge, db.Put(largeKey, []byte("value")))
+	assert.Equal(t, ErrKeyTooLarge, db.Delete(largeKey))
+	wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+	assert.Equal(t, ErrKeyTooLarge, wb.Put(largeKey, []byte("value")))
+	assert.Nil(t, db.Put(getTestKey(1), []byte("value")))
+	stat, err := db.Stat()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), stat.ReclaimableSize)
+	assert.Nil(t, db.Close())
+
+	// 損壞的索引文件導致打開失敗
+	indexFileName := filepath.Join(dir, index.BPlusTreeIndexFileName)
+	assert.Nil(t, os.WriteFile(indexFileName, bytes.Repeat([]byte{0xff}, 8192), 0644))
+	_, err = Open(opts)
+	assert.NotNil(t, err)
+
+	// 刪除索引文件後從數據文件中重建
+	assert.Nil(t, os.Remove(indexFileName))
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	defer destroyDB(db2)
+	val, err := db2.Get(getTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value"), val)
+}
+
+// 使用其他類型的索引寫入之後，B+ 樹索引不會返回過期的數據
+func TestDB_BPlusTreeIndexSwitchType(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-bptree-switch")
+	opts.DirPath = dir
+	opts.IndexType = BPlusTree
+	db, err := Open(opts)
+	assert.Nil(t, err)
+	assert.Nil(t, db.Put([]byte("a"), []byte("value-a")))
+	assert.Nil(t, db.Close())
+
+	btreeOpts := opts
+	btreeOpts.IndexType = Btree
+	db, err = Open(btreeOpts)
+	assert.Nil(t, err)
+	assert.Nil(t, db.Put([]byte("b"), []byte("value-b")))
+	assert.Nil(t, db.Delete([]byte("a")))
+	assert.Nil(t, db.Close())
+
+	db, err = Open(opts)
+	assert.Nil(t, err)
+	defer destroyDB(db)
+	val, err := db.Get([]byte("b"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value-b"), val)
+	_, err = db.Get([]byte("a"))
+	assert.Equal(t, ErrKeyNotFound, err)
+}
+
+// 崩潰時已經寫入數據文件但沒有更新到 B+ 樹索引中的記錄，在重新打開時從保存的位置加載
+func TestDB_BPlusTreeIndexReplay(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-bptree-replay")
+	opts.DirPath = dir
+	opts.IndexType = BPlusTree
+	db, err := Open(opts)
+	assert.Nil(t, err)
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, db.Put(getTestKey(i), []byte("value")))
+	}
+	assert.Nil(t, db.Sync())
+	assert.Nil(t, db.Delete(getTestKey(0)))
+	assert.Nil(t, db.Put(getTestKey(1), []byte("value-new")))
+
+	// 模擬寫入數據文件之後、更新索引之前崩潰
+	db.seqNo++
+	_, err = db.appendLogRecord(&data.LogRecord{
+		Key:   logRecordKeyWithSeq(getTestKey(10), db.seqNo, false),
+		Value: []byte("value"),
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, db.index.Close())
+	assert.Nil(t, db.closeDataFiles())
+	assert.Nil(t, db.fileLock.Unlock())
+
+	db, err = Open(opts)
+	assert.Nil(t, err)
+	defer destroyDB(db)
+	_, err = db.Get(getTestKey(0))
+	assert.Equal(t, ErrKeyNotFound, err)
+	val, err := db.Get(getTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value-new"), val)
+	for i := 2; i <= 10; i++ {
+		val, err := db.Get(getTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("value"), val)
+	}
+	assert.Equal(t, uint64(13), db.seqNo)
+	assert.Equal(t, 10, db.index.Size())
+}
+
+// 保存的位置超出數據文件的範圍時，刪除 B+ 樹索引之後重建
+func TestDB_BPlusTreeIndexInvalidCheckpoint(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-bptree-checkpoint")
+	opts.DirPath = dir
+	opts.IndexType = BPlusTree
+	db, err := Open(opts)
+	assert.Nil(t, err)
+	assert.Nil(t, db.Put(getTestKey(1), []byte("value")))
+	offset := db.activeFile.WriteOffset
+	assert.Nil(t, db.Put(getTestKey(2), []byte("value")))
+	assert.Nil(t, db.Close())
+
+	assert.Nil(t, os.Truncate(data.GetDataFileName(dir, 0), offset))
+	db, err = Open(opts)
+	assert.Nil(t, err)
+	defer destroyDB(db)
+	assert.Equal(t, 1, db.index.Size())
+	_, err = db.Get(getTestKey(2))
+	assert.Equal(t, ErrKeyNotFound, err)
+}
+
+func TestDB_Close(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-close")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	err = db.Put(getTestKey(1), []byte("value-1"))
+	assert.Nil(t, err)
+	err = db.Close()
+	assert.Nil(t, err)
+
+	// 關閉之後的操作都返回錯誤
+	err = db.Put(getTestKey(2), []byte("value-2"))
+	assert.Equal(t, ErrDatabaseClosed, err)
+	_, err = db.Get(getTestKey(1))
+	assert.Equal(t, ErrDatabaseClosed, err)
+	err = db.Delete(getTestKey(1))
+	assert.Equal(t, ErrDatabaseClosed, err)
+	err = db.Sync()
+	assert.Equal(t, ErrDatabaseClosed, err)
+	err = db.Merge()
+	assert.Equal(t, ErrDatabaseClosed, err)
+	err = db.Close()
+	assert.Equal(t, ErrDatabaseClosed, err)
+}
+
+// 關閉之後創建的迭代器是無效的，不會因為索引已經關閉而崩潰
+func TestDB_CloseIterator(t *testing.T) {
+	for _, indexType := range []IndexerType{Btree, ART, BPlusTree} {
+		opts := DefaultOptions
+		dir, _ := os.MkdirTemp("", "bitcask-go-close-iterator")
+		opts.DirPath = dir
+		opts.IndexType = indexType
+		db, err := Open(opts)
+		assert.Nil(t, err)
+
+		assert.Nil(t, db.Put(getTestKey(1), []byte("value-1")))
+		snapshot, err := db.Snapshot()
+		assert.Nil(t, err)
+		assert.Nil(t, db.Close())
+
+		iter := db.NewIterator(DefaultIteratorOptions)
+		assert.False(t, iter.Valid())
+		iter.Close()
+		iter = snapshot.NewIterator(DefaultIteratorOptions)
+		assert.False(t, iter.Valid())
+		iter.Close()
+		destroyDB(db)
+	}
+}
+
+func TestDB_Sync(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-sync")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	// 數據庫為空
+	err = db.Sync()
+	assert.Nil(t, err)
+
+	err = db.Put(getTestKey(1), []byte("value-1"))
+	assert.Nil(t, err)
+	err = db.Sync()
+	assert.Nil(t, err)
+}
+
+func TestDB_PutWithTTL(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-ttl")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	err = db.PutWithTTL(getTestKey(1), []byte("value-1"), 100*time.Millisecond)
+	assert.Nil(t, err)
+	err = db.PutWithExpiry(getTestKey(2), []byte("value-2"), time.Now().Add(time.Hour))
+	assert.Nil(t, err)
+	err = db.PutWithTTL(getTestKey(3), []byte("value-3"), 0)
+	assert.Equal(t, ErrInvalidTTL, err)
+	err = db.PutWithExpiry(getTestKey(3), []byte("value-3"), time.Now().Add(-time.Second))
+	assert.Equal(t, ErrInvalidTTL, err)
+
+	val, err := db.Get(getTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value-1"), val)
+
+	time.Sleep(150 * time.Millisecond)
+	_, err = db.Get(getTestKey(1))
+	assert.Equal(t, ErrKeyNotFound, err)
+	val, err = db.Get(getTestKey(2))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value-2"), val)
+
+	// 重啟後過期的數據不會加載到索引中
+	err = db.Close()
+	assert.Nil(t, err)
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	assert.Nil(t, db2.index.Get(getTestKey(1)))
+	assert.NotNil(t, db2.index.Get(getTestKey(2)))
+
+	// 再次寫入的數據不帶過期時間
+	err = db2.PutWithTTL(getTestKey(4), []byte("value-4"), 100*time.Millisecond)
+	assert.Nil(t, err)
+	err = db2.Put(getTestKey(4), []byte("value-4"))
+	assert.Nil(t, err)
+
+	// merge 會清理已經過期的數據，並保留未過期數據的過期時間
+	err = db2.PutWithTTL(getTestKey(5), []byte("value-5"), 100*time.Millisecond)
+	assert.Nil(t, err)
+	err = db2.PutWithTTL(getTestKey(6), []byte("value-6"), 400*time.Millisecond)
+	assert.Nil(t, err)
+	time.Sleep(150 * time.Millisecond)
+	err = db2.Merge()
+	assert.Nil(t, err)
+	err = db2.Close()
+	assert.Nil(t, err)
+
+	db3, err := Open(opts)
+	assert.Nil(t, err)
+	assert.Nil(t, db3.index.Get(getTestKey(5)))
+	assert.NotNil(t, db3.index.Get(getTestKey(6)))
+	_, err = db3.Get(getTestKey(4))
+	assert.Nil(t, err)
+	time.Sleep(300 * time.Millisecond)
+	_, err = db3.Get(getTestKey(6))
+	assert.Equal(t, ErrKeyNotFound, err)
+	err = db3.Close()
+	assert.Nil(t, err)
+}
+
+func TestOpen_TornWrite(t *testing.T) {
+	indexTypes := []IndexerType{Btree, BPlusTree}
+	for _, typ := range indexTypes {
+		opts := DefaultOptions
+		dir, _ := os.MkdirTemp("", "bitcask-go-torn-write")
+		opts.DirPath = dir
+		opts.IndexType = typ
+		db, err := Open(opts)
+		assert.Nil(t, err)
+		for i := 0; i < 10; i++ {
+			err := db.Put(getTestKey(i), []byte("value"))
+			assert.Nil(t, err)
+		}
+		assert.Nil(t, db.Close())
+
+		// 模擬寫了一半時崩潰：沒有序列號文件，活躍文件末尾只有半條記錄
+		assert.Nil(t, os.Remove(filepath.Join(dir, data.SeqNoFileName)))
+		record, _ := data.EncodeLogRecord(&data.LogRecord{
+			Key:   logRecordKeyWithSeq(getTestKey(10), 11, false),
+			Value: []byte("value"),
+		})
+		torn := record[:len(record)/2]
+		file, err := os.OpenFile(data.GetDataFileName(dir, 0), os.O_APPEND|os.O_WRONLY, 0644)
+		assert.Nil(t, err)
+		_, err = file.Write(torn)
+		assert.Nil(t, err)
+		assert.Nil(t, file.Close())
+
+		db, err = Open(opts)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(len(torn)), db.DiscardedBytes())
+		assert.Equal(t, uint64(10), db.seqNo)
+		_, err = db.Get(getTestKey(10))
+		assert.Equal(t, ErrKeyNotFound, err)
+
+		// 截斷之後寫入的數據在重啟之後仍然有效
+		err = db.Put(getTestKey(10), []byte("value"))
+		assert.Nil(t, err)
+		assert.Nil(t, db.Close())
+
+		db, err = Open(opts)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(0), db.DiscardedBytes())
+		for i := 0; i <= 10; i++ {
+			val, err := db.Get(getTestKey(i))
+			assert.Nil(t, err)
+			assert.Equal(t, []byte("value"), val)
+		}
+		destroyDB(db)
+	}
+}
+
+func TestOpen_GarbageTail(t *testing.T) {
+	// 長度信息無效的數據不能導致崩潰，也不能按照損壞的長度分配內存
+	huge := []byte{1, 2, 3, 4, data.LogRecordNormal}
+	huge = binary.AppendVarint(huge, math.MaxUint32)
+	huge = binary.AppendVarint(huge, math.MaxUint32)
+	huge = binary.AppendVarint(huge, 0)
+	tails := [][]byte{
+		bytes.Repeat([]byte{0xff}, 16),
+		bytes.Repeat([]byte{0xff}, 64),
+		huge,
+	}
+	for _, tail := range tails {
+		opts := DefaultOptions
+		dir, _ := os.MkdirTemp("", "bitcask-go-garbage-tail")
+		opts.DirPath = dir
+		db, err := Open(opts)
+		assert.Nil(t, err)
+		for i := 0; i < 10; i++ {
+			err := db.Put(getTestKey(i), []byte("value"))
+			assert.Nil(t, err)
+		}
+		assert.Nil(t, db.Close())
+
+		file, err := os.OpenFile(data.GetDataFileName(dir, 0), os.O_APPEND|os.O_WRONLY, 0644)
+		assert.Nil(t, err)
+		_, err = file.Write(tail)
+		assert.Nil(t, err)
+		assert.Nil(t, file.Close())
+
+		db, err = Open(opts)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(len(tail)), db.DiscardedBytes())
+		for i := 0; i < 10; i++ {
+			val, err := db.Get(getTestKey(i))
+			assert.Nil(t, err)
+			assert.Equal(t, []byte("value"), val)
+		}
+		destroyDB(db)
+	}
+}
+
+func TestOpen_CorruptedActiveFile(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-corrupted")
+	opts.DirPath = dir
+	defer os.RemoveAll(dir)
+	db, err := Open(opts)
+	assert.Nil(t, err)
+	for i := 0; i < 10; i++ {
+		err := db.Put(getTestKey(i), []byte("value"))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, db.Close())
+
+	// 文件中間的記錄損壞時不能截斷，否則會丟失之後的有效數據
+	fileName := data.GetDataFileName(dir, 0)
+	buf, err := os.ReadFile(fileName)
+	assert.Nil(t, err)
+	buf[len(buf)/2] ^= 0xff
+	assert.Nil(t, os.WriteFile(fileName, buf, 0644))
+
+	_, err = Open(opts)
+	assert.Equal(t, data.ErrInValidCRC, err)
+}
+
+func TestOpen_LegacyDataFile(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-legacy")
+	opts.DirPath = dir
+	defer os.RemoveAll(dir)
+
+	// 構造沒有文件頭的舊版本數據文件
+	var buf []byte
+	for i := 0; i < 10; i++ {
+		record, _ := data.EncodeLogRecord(&data.LogRecord{
+			Key:   logRecordKeyWithSeq(getTestKey(i), uint64(i+1), false),
+			Value: []byte("value"),
+		})
+		buf = append(buf, record...)
+	}
+	assert.Nil(t, os.WriteFile(data.GetDataFileName(dir, 0), buf, 0644))
+
+	db, err := Open(opts)
+	assert.Nil(t, err)
+	assert.Equal(t, data.LegacyFormatVersion, db.activeFile.Header.Version)
+	assert.Equal(t, int64(len(buf)), db.activeFile.WriteOffset)
+	for i := 0; i < 10; i++ {
+		val, err := db.Get(getTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("value"), val)
+	}
+	assert.Nil(t, db.Put(getTestKey(10), []byte("value")))
+
+	// merge 之後的數據文件升級到當前的格式
+	assert.Nil(t, db.Merge())
+	assert.Nil(t, db.Close())
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	defer destroyDB(db2)
+	assert.Equal(t, data.FormatVersion, db2.olderFiles[0].Header.Version)
+	for i := 0; i <= 10; i++ {
+		val, err := db2.Get(getTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("value"), val)
+	}
+}
+
+func TestOpen_UnsupportedVersion(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-version")
+	opts.DirPath = dir
+	defer os.RemoveAll(dir)
+
+	header := data.EncodeFileHeader(&data.FileHeader{Version: data.FormatVersion + 1, Checksum: data.ChecksumCRC32IEEE})
+	assert.Nil(t, os.WriteFile(data.GetDataFileName(dir, 0), header, 0644))
+	_, err := Open(opts)
+	assert.Equal(t, data.ErrUnsupportedVersion, err)
+
+	// 打開失敗之後釋放文件鎖
+	assert.Nil(t, os.Remove(data.GetDataFileName(dir, 0)))
+	db, err := Open(opts)
+	assert.Nil(t, err)
+	assert.Nil(t, db.Close())
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package bitcask_go
+
+import "syscall"
+
+// availableDiskSize 獲取目錄所在磁盤的剩餘可用空間
+func availableDiskSize(dirPath string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dirPath, &stat); err != nil {
+		return 0, err
+	}
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
@@ -0,0 +1,16 @@
+package bitcask_go
+
+import "golang.org/x/sys/windows"
+
+// availableDiskSize 獲取目錄所在磁盤的剩餘可用空間
+func availableDiskSize(dirPath string) (uint64, error) {
+	dirName, err := windows.UTF16PtrFromString(dirPath)
+	if err != nil {
+		return 0, err
+	}
+	var freeBytes uint64
+	if err := windows.GetDiskFreeSpaceEx(dirName, &freeBytes, nil, nil); err != nil {
+		return 0, err
+	}
+	return freeBytes, nil
+}
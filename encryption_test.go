@@ -0,0 +1,187 @@
+package bitcask_go
+
+import (
+	"bitcask-go/data"
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestDB_Encryption(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-encryption")
+	opts.DirPath = dir
+	opts.EncryptionKeys = map[uint32][]byte{1: bytes.Repeat([]byte("a"), 32)}
+	opts.EncryptionKeyId = 1
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, db.Put(getTestKey(i), []byte("secret-value")))
+	}
+	assert.Nil(t, db.Delete(getTestKey(99)))
+	assert.Nil(t, db.Sync())
+
+	// 數據文件中不包含明文的 key 和 value
+	buf, err := os.ReadFile(data.GetDataFileName(dir, 0))
+	assert.Nil(t, err)
+	assert.False(t, bytes.Contains(buf, []byte("secret-value")))
+	assert.False(t, bytes.Contains(buf, getTestKey(1)))
+
+	// 加密不影響前綴遍歷
+	iter := db.NewIterator(IteratorOptions{Prefix: getTestKey(1)})
+	assert.True(t, iter.Valid())
+	val, err := iter.Value()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("secret-value"), val)
+	iter.Close()
+	assert.Nil(t, db.Close())
+
+	// 沒有密鑰時無法打開
+	noKeyOpts := opts
+	noKeyOpts.EncryptionKeys = nil
+	noKeyOpts.EncryptionKeyId = 0
+	_, err = Open(noKeyOpts)
+	assert.Equal(t, data.ErrUnknownEncryptionKey, err)
+
+	// 密鑰錯誤時無法打開，數據文件不會被當作不完整的寫入截斷
+	wrongKeyOpts := opts
+	wrongKeyOpts.EncryptionKeys = map[uint32][]byte{1: bytes.Repeat([]byte("c"), 32)}
+	_, err = Open(wrongKeyOpts)
+	assert.Equal(t, data.ErrDecryptFailed, err)
+
+	// 輪換密鑰，舊的數據使用舊的密鑰讀取，新的數據使用新的密鑰寫入
+	opts.EncryptionKeys = map[uint32][]byte{
+		1: bytes.Repeat([]byte("a"), 32),
+		2: bytes.Repeat([]byte("b"), 32),
+	}
+	opts.EncryptionKeyId = 2
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	assert.Nil(t, db2.Put(getTestKey(100), []byte("new-value")))
+	_, err = db2.Get(getTestKey(99))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	// merge 時使用新的密鑰重新加密有效數據
+	assert.Nil(t, db2.Merge())
+	assert.Nil(t, db2.Close())
+
+	opts.EncryptionKeys = map[uint32][]byte{2: bytes.Repeat([]byte("b"), 32)}
+	db3, err := Open(opts)
+	assert.Nil(t, err)
+	defer destroyDB(db3)
+	for i := 0; i < 99; i++ {
+		val, err := db3.Get(getTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("secret-value"), val)
+	}
+	val, err = db3.Get(getTestKey(100))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("new-value"), val)
+}
+
+func TestDB_EncryptionInvalidOptions(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-encryption-2")
+	defer os.RemoveAll(dir)
+	opts.DirPath = dir
+
+	opts.EncryptionKeyId = 1
+	_, err := Open(opts)
+	assert.NotNil(t, err)
+
+	opts.EncryptionKeys = map[uint32][]byte{1: []byte("short key")}
+	_, err = Open(opts)
+	assert.NotNil(t, err)
+}
+
+func TestDB_EncryptPlaintextByMerge(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-encryption-merge")
+	opts.DirPath = dir
+	opts.DataFileSize = 1024
+	db, err := Open(opts)
+	assert.Nil(t, err)
+	for i := 0; i < 200; i++ {
+		assert.Nil(t, db.Put(getTestKey(i), []byte("plaintext-value")))
+	}
+	assert.Nil(t, db.Close())
+
+	// 重新加密之後記錄變長，merge 後的文件比參與 merge 的文件更多
+	opts.EncryptionKeys = map[uint32][]byte{1: bytes.Repeat([]byte("a"), 32)}
+	opts.EncryptionKeyId = 1
+	db, err = Open(opts)
+	assert.Nil(t, err)
+	assert.Nil(t, db.Merge())
+	for i := 200; i < 250; i++ {
+		assert.Nil(t, db.Put(getTestKey(i), []byte("plaintext-value")))
+	}
+	assert.Nil(t, db.Close())
+
+	// merge 後的文件不會覆蓋 merge 之後寫入的數據
+	db, err = Open(opts)
+	assert.Nil(t, err)
+	defer destroyDB(db)
+	for i := 0; i < 250; i++ {
+		val, err := db.Get(getTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("plaintext-value"), val)
+	}
+	buf, err := os.ReadFile(data.GetDataFileName(dir, 0))
+	assert.Nil(t, err)
+	assert.False(t, bytes.Contains(buf, []byte("plaintext-value")))
+}
+
+func TestDB_EncryptPlaintextByMergeGrowth(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-encryption-merge-2")
+	opts.DirPath = dir
+	opts.DataFileSize = 1024
+	db, err := Open(opts)
+	assert.Nil(t, err)
+	// 很短的記錄加密之後的長度超過原來的兩倍，merge 後需要的文件比原來的多
+	for i := 0; i < 500; i++ {
+		assert.Nil(t, db.Put([]byte(strconv.Itoa(i)), []byte("v")))
+	}
+	assert.Nil(t, db.Close())
+
+	opts.EncryptionKeys = map[uint32][]byte{1: bytes.Repeat([]byte("a"), 32)}
+	opts.EncryptionKeyId = 1
+	db, err = Open(opts)
+	assert.Nil(t, err)
+	olderFileNum := len(db.olderFiles) + 1
+	assert.Nil(t, db.Merge())
+	activeFileId := db.activeFile.FileId
+	assert.Greater(t, int(activeFileId), 2*olderFileNum)
+	// 重複 merge 時文件 id 不會不斷增長
+	assert.Nil(t, db.Merge())
+	assert.Equal(t, activeFileId+1, db.activeFile.FileId)
+	for i := 500; i < 550; i++ {
+		assert.Nil(t, db.Put([]byte(strconv.Itoa(i)), []byte("v")))
+	}
+	assert.Nil(t, db.Close())
+
+	db, err = Open(opts)
+	assert.Nil(t, err)
+	defer destroyDB(db)
+	for i := 0; i < 550; i++ {
+		val, err := db.Get([]byte(strconv.Itoa(i)))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("v"), val)
+	}
+	// merge 之後所有的記錄都被加密
+	fileIds, err := ListDataFileIds(dir)
+	assert.Nil(t, err)
+	for _, fid := range fileIds {
+		buf, err := os.ReadFile(data.GetDataFileName(dir, fid))
+		assert.Nil(t, err)
+		for offset := int64(data.FileHeaderSize); offset < int64(len(buf)); {
+			header, headerSize := data.DecodeLogRecordHeader(buf[offset:])
+			assert.True(t, header.Encrypted())
+			offset += headerSize + int64(header.KeySize()) + int64(header.ValueSize())
+		}
+	}
+}
@@ -4,8 +4,22 @@ import "errors"
 
 var (
 	ErrKeyIsEmpty             = errors.New("the key is empty")
+	ErrKeyTooLarge            = errors.New("the key exceeds the max key size")
 	ErrIndexUpdateFailed      = errors.New("failed to update index")
 	ErrKeyNotFound            = errors.New("key is not found in the database")
 	ErrDataFileNotFound       = errors.New("data file is not found")
 	ErrDataDirectoryCorrupted = errors.New("database directory may be corrupted")
+	ErrMergeIsProgress        = errors.New("merge is in progress, try again later")
+	ErrExceedMaxBatchNum      = errors.New("exceed the max batch num")
+	ErrDatabaseIsUsing        = errors.New("the database directory is used by another process")
+	ErrDatabaseClosed         = errors.New("the database is closed")
+	ErrInvalidTTL             = errors.New("the ttl must be in the future")
+	ErrSnapshotReleased       = errors.New("the snapshot is released")
+	ErrTxnConflict            = errors.New("transaction conflict, the keys read have been modified")
+	ErrTxnClosed              = errors.New("the transaction is already committed or rolled back")
+	ErrDirectoryNotEmpty      = errors.New("the directory is not empty")
+	ErrBackupCorrupted        = errors.New("the backup is corrupted")
+	ErrBackupMissing          = errors.New("the backup containing the file is missing")
+	ErrNoEnoughSpaceForMerge  = errors.New("no enough disk space for merge")
+	ErrMergeFileIdsExhausted  = errors.New("the merged data files exceed the file ids reserved for merge")
 )
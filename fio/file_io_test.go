@@ -58,3 +58,24 @@ func TestFileIO_Read(t *testing.T) {
 	assert.Equal(t, []byte("key-a"), buf)
 
 }
+
+func TestFileIO_Truncate(t *testing.T) {
+	path := filepath.Join("/tmp", "0004.data")
+	fio, err := NewFileIOManager(path)
+	defer destroyFile(path)
+	assert.Nil(t, err)
+
+	_, err = fio.Write([]byte("key-a"))
+	assert.Nil(t, err)
+	err = fio.Truncate(3)
+	assert.Nil(t, err)
+
+	// 截斷之後從新的末尾繼續寫入
+	_, err = fio.Write([]byte("-b"))
+	assert.Nil(t, err)
+	b := make([]byte, 5)
+	n, err := fio.Read(b, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, n)
+	assert.Equal(t, []byte("key-b"), b)
+}
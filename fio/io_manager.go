@@ -18,9 +18,29 @@ type IOManager interface {
 
 	// Size 獲取文件大小
 	Size() (int64, error)
+
+	// Truncate 將文件截斷到指定的大小
+	Truncate(int64) error
 }
 
-// NewIOManager 初始化 IOManager， 目前只支持標準 FileID
-func NewIOManager(fileName string) (IOManager, error) {
-	return NewFileIOManager(fileName)
+type FileIOType = byte
+
+const (
+	// StandardFIO 標準文件 IO
+	StandardFIO FileIOType = iota
+
+	// MemoryMap 內存文件映射，只讀
+	MemoryMap
+)
+
+// NewIOManager 初始化 IOManager，支持標準 FileIO 和 MMap
+func NewIOManager(fileName string, ioType FileIOType) (IOManager, error) {
+	switch ioType {
+	case StandardFIO:
+		return NewFileIOManager(fileName)
+	case MemoryMap:
+		return NewMMapIOManager(fileName)
+	default:
+		panic("unsupported io type")
+	}
 }
@@ -0,0 +1,69 @@
+package fio
+
+import (
+	"errors"
+	"golang.org/x/exp/mmap"
+	"os"
+)
+
+var ErrMMapReadOnly = errors.New("memory map io manager is read only")
+
+// MMap IO，內存文件映射，只用於讀取數據
+type MMap struct {
+	fileName string
+	readerAt *mmap.ReaderAt
+}
+
+// NewMMapIOManager 初始化 MMap IO
+func NewMMapIOManager(fileName string) (*MMap, error) {
+	// 文件不存在時先創建
+	fd, err := os.OpenFile(fileName, os.O_CREATE, DataFilePerm)
+	if err != nil {
+		return nil, err
+	}
+	if err := fd.Close(); err != nil {
+		return nil, err
+	}
+
+	readerAt, err := mmap.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return &MMap{fileName: fileName, readerAt: readerAt}, nil
+}
+
+func (mmap *MMap) Read(b []byte, offset int64) (int, error) {
+	return mmap.readerAt.ReadAt(b, offset)
+}
+
+func (mmap *MMap) Write([]byte) (int, error) {
+	return 0, ErrMMapReadOnly
+}
+
+func (mmap *MMap) Sync() error {
+	return ErrMMapReadOnly
+}
+
+func (mmap *MMap) Close() error {
+	return mmap.readerAt.Close()
+}
+
+func (mmap *MMap) Size() (int64, error) {
+	return int64(mmap.readerAt.Len()), nil
+}
+
+// Truncate 截斷文件之後重新建立映射
+func (mmap *MMap) Truncate(size int64) error {
+	if err := mmap.readerAt.Close(); err != nil {
+		return err
+	}
+	if err := os.Truncate(mmap.fileName, size); err != nil {
+		return err
+	}
+	reopened, err := NewMMapIOManager(mmap.fileName)
+	if err != nil {
+		return err
+	}
+	mmap.readerAt = reopened.readerAt
+	return nil
+}
@@ -0,0 +1,74 @@
+package fio
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+func TestMMap_Read(t *testing.T) {
+	path := filepath.Join("/tmp", "mmap-a.data")
+	defer destroyFile(path)
+
+	mmapIO, err := NewMMapIOManager(path)
+	assert.Nil(t, err)
+
+	// 文件為空
+	b1 := make([]byte, 10)
+	n1, err := mmapIO.Read(b1, 0)
+	assert.Equal(t, 0, n1)
+	assert.Equal(t, io.EOF, err)
+	_ = mmapIO.Close()
+
+	fio, err := NewFileIOManager(path)
+	assert.Nil(t, err)
+	_, err = fio.Write([]byte("aa"))
+	assert.Nil(t, err)
+	_, err = fio.Write([]byte("bb"))
+	assert.Nil(t, err)
+	_ = fio.Close()
+
+	mmapIO2, err := NewMMapIOManager(path)
+	assert.Nil(t, err)
+	defer mmapIO2.Close()
+
+	size, err := mmapIO2.Size()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), size)
+
+	b2 := make([]byte, 2)
+	n2, err := mmapIO2.Read(b2, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n2)
+	assert.Equal(t, []byte("bb"), b2)
+
+	_, err = mmapIO2.Write([]byte("cc"))
+	assert.Equal(t, ErrMMapReadOnly, err)
+}
+
+func TestMMap_Truncate(t *testing.T) {
+	path := filepath.Join("/tmp", "mmap-b.data")
+	defer destroyFile(path)
+
+	fio, err := NewFileIOManager(path)
+	assert.Nil(t, err)
+	_, err = fio.Write([]byte("aabbcc"))
+	assert.Nil(t, err)
+	_ = fio.Close()
+
+	mmapIO, err := NewMMapIOManager(path)
+	assert.Nil(t, err)
+	defer mmapIO.Close()
+
+	err = mmapIO.Truncate(4)
+	assert.Nil(t, err)
+	size, err := mmapIO.Size()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), size)
+
+	b := make([]byte, 4)
+	_, err = mmapIO.Read(b, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("aabb"), b)
+}
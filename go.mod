@@ -3,12 +3,23 @@ module bitcask-go
 go 1.22.1
 
 require (
+	github.com/go-redis/redis/v8 v8.11.5
+	github.com/gofrs/flock v0.8.1
 	github.com/google/btree v1.1.2
+	github.com/klauspost/compress v1.18.0
 	github.com/stretchr/testify v1.9.0
+	github.com/tidwall/match v1.1.1
+	github.com/tidwall/redcon v1.6.2
+	go.etcd.io/bbolt v1.3.10
+	golang.org/x/exp v0.0.0-20240416160154-fe59bbe5cc7f
+	golang.org/x/sys v0.9.0
 )
 
 require (
+	github.com/cespare/xxhash/v2 v2.1.2 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/tidwall/btree v1.1.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,523 @@
+package index
+
+import (
+	"bitcask-go/data"
+	"bytes"
+	"sort"
+	"sync"
+)
+
+// AdaptiveRadixTree 自適應基數樹索引
+// 主要參考論文 The Adaptive Radix Tree: ARTful Indexing for Main-Memory Databases
+type AdaptiveRadixTree struct {
+	tree *artTree
+	lock *sync.RWMutex
+}
+
+// NewART 初始化自適應基數樹索引
+func NewART() *AdaptiveRadixTree {
+	return &AdaptiveRadixTree{
+		tree: &artTree{},
+		lock: new(sync.RWMutex),
+	}
+}
+
+func (art *AdaptiveRadixTree) Put(key []byte, pos *data.LogRecordPos) *data.LogRecordPos {
+	art.lock.Lock()
+	defer art.lock.Unlock()
+	oldPos := art.tree.search(key)
+	art.tree.insert(key, pos)
+	return oldPos
+}
+
+func (art *AdaptiveRadixTree) Get(key []byte) *data.LogRecordPos {
+	art.lock.RLock()
+	defer art.lock.RUnlock()
+	return art.tree.search(key)
+}
+
+func (art *AdaptiveRadixTree) Delete(key []byte) (*data.LogRecordPos, bool) {
+	art.lock.Lock()
+	defer art.lock.Unlock()
+	oldPos := art.tree.search(key)
+	if !art.tree.delete(key) {
+		return nil, false
+	}
+	return oldPos, true
+}
+
+func (art *AdaptiveRadixTree) Size() int {
+	art.lock.RLock()
+	defer art.lock.RUnlock()
+	return art.tree.size
+}
+
+func (art *AdaptiveRadixTree) Close() error {
+	return nil
+}
+
+func (art *AdaptiveRadixTree) Iterator(reverse bool) Iterator {
+	art.lock.RLock()
+	defer art.lock.RUnlock()
+	return newARTIterator(art.tree, reverse)
+}
+
+// ART 索引迭代器
+type artIterator struct {
+	curIndex int     // 當前遍歷的下標位置
+	reverse  bool    // 是否反向遍歷
+	values   []*Item // key 與 位置索引信息
+}
+
+func newARTIterator(tree *artTree, reverse bool) *artIterator {
+	values := make([]*Item, 0, tree.size)
+
+	// 將所有的數據存放到數組中
+	tree.walk(tree.root, reverse, func(leaf *artNode) {
+		values = append(values, &Item{key: leaf.key, pos: leaf.pos})
+	})
+
+	return &artIterator{
+		curIndex: 0,
+		reverse:  reverse,
+		values:   values,
+	}
+}
+
+func (ai *artIterator) Rewind() {
+	ai.curIndex = 0
+}
+
+func (ai *artIterator) Seek(key []byte) {
+	if ai.reverse {
+		ai.curIndex = sort.Search(len(ai.values), func(i int) bool {
+			return bytes.Compare(ai.values[i].key, key) <= 0
+		})
+	} else {
+		ai.curIndex = sort.Search(len(ai.values), func(i int) bool {
+			return bytes.Compare(ai.values[i].key, key) >= 0
+		})
+	}
+}
+
+func (ai *artIterator) Next() {
+	ai.curIndex++
+}
+
+func (ai *artIterator) Valid() bool {
+	return ai.curIndex < len(ai.values)
+}
+
+func (ai *artIterator) Key() []byte {
+	return ai.values[ai.curIndex].key
+}
+
+func (ai *artIterator) Value() *data.LogRecordPos {
+	return ai.values[ai.curIndex].pos
+}
+
+func (ai *artIterator) Close() {
+	ai.values = nil
+}
+
+// 節點類型，內部節點根據子節點的數量選擇不同的存儲方式
+const (
+	artLeaf uint8 = iota
+	artNode4
+	artNode16
+	artNode48
+	artNode256
+)
+
+// artTree 自適應基數樹
+type artTree struct {
+	root *artNode
+	size int // 葉子節點，即 key 的數量
+}
+
+// artNode 基數樹節點
+// 葉子節點保存完整的 key，內部節點通過 prefix 實現路徑壓縮
+type artNode struct {
+	kind uint8
+
+	// 葉子節點
+	key []byte
+	pos *data.LogRecordPos
+
+	// 內部節點
+	prefix      []byte     // 壓縮的公共路徑
+	terminal    *artNode   // key 恰好在此節點結束時對應的葉子節點
+	numChildren int        // 子節點數量
+	keys        []byte     // Node4/Node16 為有序的邊，Node48 為 256 個字節到子節點下標(+1)的映射
+	children    []*artNode // 子節點
+}
+
+func newArtLeaf(key []byte, pos *data.LogRecordPos) *artNode {
+	return &artNode{kind: artLeaf, key: key, pos: pos}
+}
+
+func newArtInnerNode(kind uint8) *artNode {
+	n := &artNode{kind: kind}
+	switch kind {
+	case artNode4:
+		n.keys = make([]byte, 0, 4)
+		n.children = make([]*artNode, 0, 4)
+	case artNode16:
+		n.keys = make([]byte, 0, 16)
+		n.children = make([]*artNode, 0, 16)
+	case artNode48:
+		n.keys = make([]byte, 256)
+		n.children = make([]*artNode, 48)
+	case artNode256:
+		n.children = make([]*artNode, 256)
+	}
+	return n
+}
+
+func (t *artTree) search(key []byte) *data.LogRecordPos {
+	n, depth := t.root, 0
+	for n != nil {
+		if n.kind == artLeaf {
+			if bytes.Equal(n.key, key) {
+				return n.pos
+			}
+			return nil
+		}
+		if !bytes.HasPrefix(key[depth:], n.prefix) {
+			return nil
+		}
+		depth += len(n.prefix)
+		if depth == len(key) {
+			if n.terminal != nil {
+				return n.terminal.pos
+			}
+			return nil
+		}
+		child := n.findChild(key[depth])
+		if child == nil {
+			return nil
+		}
+		n = *child
+		depth++
+	}
+	return nil
+}
+
+func (t *artTree) insert(key []byte, pos *data.LogRecordPos) {
+	if t.insertAt(&t.root, key, pos, 0) {
+		t.size++
+	}
+}
+
+// insertAt 在 ref 指向的子樹中插入數據，新增了 key 則返回 true
+func (t *artTree) insertAt(ref **artNode, key []byte, pos *data.LogRecordPos, depth int) bool {
+	n := *ref
+	if n == nil {
+		*ref = newArtLeaf(key, pos)
+		return true
+	}
+
+	if n.kind == artLeaf {
+		// key 已經存在，用新的 pos 替換舊的 pos
+		if bytes.Equal(n.key, key) {
+			n.pos = pos
+			return false
+		}
+		// 將葉子節點展開為內部節點，公共部分作為壓縮路徑
+		lcp := longestCommonPrefix(n.key[depth:], key[depth:])
+		inner := newArtInnerNode(artNode4)
+		inner.prefix = append([]byte{}, key[depth:depth+lcp]...)
+		depth += lcp
+		inner.addLeaf(n, depth)
+		inner.addLeaf(newArtLeaf(key, pos), depth)
+		*ref = inner
+		return true
+	}
+
+	// 壓縮路徑不匹配，需要在不匹配的位置分裂節點
+	if p := longestCommonPrefix(n.prefix, key[depth:]); p < len(n.prefix) {
+		inner := newArtInnerNode(artNode4)
+		inner.prefix = n.prefix[:p:p]
+		inner.addChild(n.prefix[p], n)
+		n.prefix = n.prefix[p+1:]
+		inner.addLeaf(newArtLeaf(key, pos), depth+p)
+		*ref = inner
+		return true
+	}
+
+	depth += len(n.prefix)
+	if depth == len(key) {
+		if n.terminal != nil {
+			n.terminal.pos = pos
+			return false
+		}
+		n.terminal = newArtLeaf(key, pos)
+		return true
+	}
+
+	if child := n.findChild(key[depth]); child != nil {
+		return t.insertAt(child, key, pos, depth+1)
+	}
+	n.addChild(key[depth], newArtLeaf(key, pos))
+	return true
+}
+
+func (t *artTree) delete(key []byte) bool {
+	if t.deleteAt(&t.root, key, 0) {
+		t.size--
+		return true
+	}
+	return false
+}
+
+// deleteAt 在 ref 指向的子樹中刪除數據，刪除成功則返回 true
+func (t *artTree) deleteAt(ref **artNode, key []byte, depth int) bool {
+	n := *ref
+	if n == nil {
+		return false
+	}
+	if n.kind == artLeaf {
+		if bytes.Equal(n.key, key) {
+			*ref = nil
+			return true
+		}
+		return false
+	}
+
+	if !bytes.HasPrefix(key[depth:], n.prefix) {
+		return false
+	}
+	depth += len(n.prefix)
+	if depth == len(key) {
+		if n.terminal == nil {
+			return false
+		}
+		n.terminal = nil
+	} else {
+		child := n.findChild(key[depth])
+		if child == nil || !t.deleteAt(child, key, depth+1) {
+			return false
+		}
+		if *child == nil {
+			n.removeChild(key[depth])
+		}
+	}
+	*ref = n.compact()
+	return true
+}
+
+// findChild 查找邊對應的子節點，返回子節點所在位置的指針
+func (n *artNode) findChild(b byte) **artNode {
+	switch n.kind {
+	case artNode4, artNode16:
+		i := sort.Search(len(n.keys), func(i int) bool { return n.keys[i] >= b })
+		if i < len(n.keys) && n.keys[i] == b {
+			return &n.children[i]
+		}
+	case artNode48:
+		if idx := n.keys[b]; idx > 0 {
+			return &n.children[idx-1]
+		}
+	case artNode256:
+		if n.children[b] != nil {
+			return &n.children[b]
+		}
+	}
+	return nil
+}
+
+// addLeaf 將葉子節點添加到內部節點中，depth 為當前節點壓縮路徑之後的位置
+func (n *artNode) addLeaf(leaf *artNode, depth int) {
+	if len(leaf.key) == depth {
+		n.terminal = leaf
+		return
+	}
+	n.addChild(leaf.key[depth], leaf)
+}
+
+// addChild 添加子節點，容量不足時擴展為更大的節點類型
+func (n *artNode) addChild(b byte, child *artNode) {
+	switch n.kind {
+	case artNode4, artNode16:
+		if n.numChildren == cap(n.keys) {
+			n.grow()
+			n.addChild(b, child)
+			return
+		}
+		i := sort.Search(len(n.keys), func(i int) bool { return n.keys[i] >= b })
+		n.keys = append(n.keys, 0)
+		n.children = append(n.children, nil)
+		copy(n.keys[i+1:], n.keys[i:])
+		copy(n.children[i+1:], n.children[i:])
+		n.keys[i] = b
+		n.children[i] = child
+	case artNode48:
+		if n.numChildren == 48 {
+			n.grow()
+			n.addChild(b, child)
+			return
+		}
+		// 找到一個空閒的位置
+		idx := 0
+		for n.children[idx] != nil {
+			idx++
+		}
+		n.children[idx] = child
+		n.keys[b] = byte(idx + 1)
+	case artNode256:
+		n.children[b] = child
+	}
+	n.numChildren++
+}
+
+// removeChild 刪除邊對應的子節點
+func (n *artNode) removeChild(b byte) {
+	switch n.kind {
+	case artNode4, artNode16:
+		i := sort.Search(len(n.keys), func(i int) bool { return n.keys[i] >= b })
+		if i == len(n.keys) || n.keys[i] != b {
+			return
+		}
+		n.keys = append(n.keys[:i], n.keys[i+1:]...)
+		copy(n.children[i:], n.children[i+1:])
+		n.children[len(n.children)-1] = nil
+		n.children = n.children[:len(n.children)-1]
+	case artNode48:
+		idx := n.keys[b]
+		if idx == 0 {
+			return
+		}
+		n.children[idx-1] = nil
+		n.keys[b] = 0
+	case artNode256:
+		if n.children[b] == nil {
+			return
+		}
+		n.children[b] = nil
+	}
+	n.numChildren--
+}
+
+// grow 將節點擴展為更大的節點類型
+func (n *artNode) grow() {
+	var kind uint8
+	switch n.kind {
+	case artNode4:
+		kind = artNode16
+	case artNode16:
+		kind = artNode48
+	case artNode48:
+		kind = artNode256
+	default:
+		return
+	}
+	n.resize(kind)
+}
+
+// compact 刪除數據後收縮節點
+// 沒有子節點時退化為葉子節點，只有一個子節點時與子節點合併壓縮路徑
+func (n *artNode) compact() *artNode {
+	if n.numChildren == 0 {
+		// terminal 可能為 nil，此時整個節點被刪除
+		return n.terminal
+	}
+	if n.numChildren == 1 && n.terminal == nil {
+		var b byte
+		var child *artNode
+		n.eachChild(false, func(edge byte, c *artNode) {
+			b, child = edge, c
+		})
+		if child.kind == artLeaf {
+			return child
+		}
+		prefix := make([]byte, 0, len(n.prefix)+1+len(child.prefix))
+		prefix = append(prefix, n.prefix...)
+		prefix = append(prefix, b)
+		child.prefix = append(prefix, child.prefix...)
+		return child
+	}
+
+	switch {
+	case n.kind == artNode16 && n.numChildren <= 3:
+		n.resize(artNode4)
+	case n.kind == artNode48 && n.numChildren <= 12:
+		n.resize(artNode16)
+	case n.kind == artNode256 && n.numChildren <= 37:
+		n.resize(artNode48)
+	}
+	return n
+}
+
+// resize 將子節點轉移到指定類型的新節點中
+func (n *artNode) resize(kind uint8) {
+	newNode := newArtInnerNode(kind)
+	n.eachChild(false, func(b byte, child *artNode) {
+		newNode.addChild(b, child)
+	})
+	n.kind = kind
+	n.keys = newNode.keys
+	n.children = newNode.children
+}
+
+// eachChild 按照邊的順序遍歷子節點
+func (n *artNode) eachChild(reverse bool, fn func(b byte, child *artNode)) {
+	visit := func(i int) {
+		switch n.kind {
+		case artNode4, artNode16:
+			if i < len(n.keys) {
+				fn(n.keys[i], n.children[i])
+			}
+		case artNode48:
+			if idx := n.keys[i]; idx > 0 {
+				fn(byte(i), n.children[idx-1])
+			}
+		case artNode256:
+			if n.children[i] != nil {
+				fn(byte(i), n.children[i])
+			}
+		}
+	}
+
+	count := len(n.keys)
+	if n.kind == artNode48 || n.kind == artNode256 {
+		count = 256
+	}
+	if reverse {
+		for i := count - 1; i >= 0; i-- {
+			visit(i)
+		}
+	} else {
+		for i := 0; i < count; i++ {
+			visit(i)
+		}
+	}
+}
+
+// walk 按照 key 的順序遍歷所有的葉子節點
+// 正向遍歷時 terminal 比所有子節點都小，反向遍歷時則最後訪問
+func (t *artTree) walk(n *artNode, reverse bool, fn func(leaf *artNode)) {
+	if n == nil {
+		return
+	}
+	if n.kind == artLeaf {
+		fn(n)
+		return
+	}
+	if !reverse && n.terminal != nil {
+		fn(n.terminal)
+	}
+	n.eachChild(reverse, func(_ byte, child *artNode) {
+		t.walk(child, reverse, fn)
+	})
+	if reverse && n.terminal != nil {
+		fn(n.terminal)
+	}
+}
+
+func longestCommonPrefix(a, b []byte) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
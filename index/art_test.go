@@ -0,0 +1,143 @@
+package index
+
+import (
+	"bitcask-go/data"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestAdaptiveRadixTree_Put(t *testing.T) {
+	art := NewART()
+	res1 := art.Put(nil, &data.LogRecordPos{Fid: 1, Offset: 100})
+	assert.Nil(t, res1)
+	res2 := art.Put([]byte("key-1"), &data.LogRecordPos{Fid: 1, Offset: 12})
+	assert.Nil(t, res2)
+	res3 := art.Put([]byte("key-1"), &data.LogRecordPos{Fid: 1, Offset: 20})
+	assert.Equal(t, int64(12), res3.Offset)
+	assert.Equal(t, 2, art.Size())
+}
+
+func TestAdaptiveRadixTree_Get(t *testing.T) {
+	art := NewART()
+	art.Put(nil, &data.LogRecordPos{Fid: 1, Offset: 100})
+	art.Put([]byte("key"), &data.LogRecordPos{Fid: 1, Offset: 10})
+	art.Put([]byte("key-1"), &data.LogRecordPos{Fid: 1, Offset: 12})
+	art.Put([]byte("key-12"), &data.LogRecordPos{Fid: 2, Offset: 14})
+
+	pos := art.Get(nil)
+	assert.Equal(t, int64(100), pos.Offset)
+	pos = art.Get([]byte("key"))
+	assert.Equal(t, int64(10), pos.Offset)
+	pos = art.Get([]byte("key-12"))
+	assert.Equal(t, uint32(2), pos.Fid)
+	assert.Nil(t, art.Get([]byte("key-2")))
+	assert.Nil(t, art.Get([]byte("ke")))
+
+	art.Put([]byte("key-1"), &data.LogRecordPos{Fid: 3, Offset: 30})
+	pos = art.Get([]byte("key-1"))
+	assert.Equal(t, uint32(3), pos.Fid)
+}
+
+func TestAdaptiveRadixTree_Delete(t *testing.T) {
+	art := NewART()
+	_, ok := art.Delete([]byte("not-exist"))
+	assert.False(t, ok)
+
+	art.Put([]byte("key"), &data.LogRecordPos{Fid: 1, Offset: 10})
+	art.Put([]byte("key-1"), &data.LogRecordPos{Fid: 1, Offset: 12})
+	art.Put([]byte("key-2"), &data.LogRecordPos{Fid: 1, Offset: 14})
+
+	pos, ok := art.Delete([]byte("key"))
+	assert.True(t, ok)
+	assert.Equal(t, int64(10), pos.Offset)
+	_, ok = art.Delete([]byte("key"))
+	assert.False(t, ok)
+	assert.Nil(t, art.Get([]byte("key")))
+	assert.NotNil(t, art.Get([]byte("key-1")))
+
+	_, ok = art.Delete([]byte("key-1"))
+	assert.True(t, ok)
+	_, ok = art.Delete([]byte("key-2"))
+	assert.True(t, ok)
+	assert.Nil(t, art.Get([]byte("key-2")))
+	assert.Equal(t, 0, art.tree.size)
+	assert.Nil(t, art.tree.root)
+}
+
+func TestAdaptiveRadixTree_Iterator(t *testing.T) {
+	art := NewART()
+	iter1 := art.Iterator(false)
+	assert.False(t, iter1.Valid())
+
+	keys := []string{"ccde", "adse", "bbde", "bade", "b", "bb"}
+	for _, key := range keys {
+		art.Put([]byte(key), &data.LogRecordPos{Fid: 1, Offset: 12})
+	}
+
+	iter2 := art.Iterator(false)
+	var got []string
+	for iter2.Rewind(); iter2.Valid(); iter2.Next() {
+		got = append(got, string(iter2.Key()))
+	}
+	assert.Equal(t, []string{"adse", "b", "bade", "bb", "bbde", "ccde"}, got)
+
+	iter3 := art.Iterator(true)
+	got = nil
+	for iter3.Rewind(); iter3.Valid(); iter3.Next() {
+		got = append(got, string(iter3.Key()))
+	}
+	assert.Equal(t, []string{"ccde", "bbde", "bb", "bade", "b", "adse"}, got)
+
+	iter4 := art.Iterator(false)
+	iter4.Seek([]byte("bc"))
+	assert.Equal(t, "ccde", string(iter4.Key()))
+
+	iter5 := art.Iterator(true)
+	iter5.Seek([]byte("bc"))
+	assert.Equal(t, "bbde", string(iter5.Key()))
+}
+
+// 大量隨機數據，覆蓋節點的擴展與收縮
+func TestAdaptiveRadixTree_Random(t *testing.T) {
+	art := NewART()
+	r := rand.New(rand.NewSource(1))
+	expected := make(map[string]int64)
+	for i := 0; i < 20000; i++ {
+		key := fmt.Sprintf("user:%d:%x", r.Intn(50), r.Intn(5000))
+		if r.Intn(4) == 0 {
+			_, ok := expected[key]
+			_, deleted := art.Delete([]byte(key))
+			assert.Equal(t, ok, deleted)
+			delete(expected, key)
+			continue
+		}
+		art.Put([]byte(key), &data.LogRecordPos{Fid: 1, Offset: int64(i)})
+		expected[key] = int64(i)
+	}
+
+	var keys []string
+	for key, offset := range expected {
+		keys = append(keys, key)
+		pos := art.Get([]byte(key))
+		assert.NotNil(t, pos)
+		assert.Equal(t, offset, pos.Offset)
+	}
+	sort.Strings(keys)
+
+	var got []string
+	iter := art.Iterator(false)
+	for ; iter.Valid(); iter.Next() {
+		got = append(got, string(iter.Key()))
+	}
+	assert.Equal(t, keys, got)
+	assert.Equal(t, len(keys), art.tree.size)
+
+	for _, key := range keys {
+		_, ok := art.Delete([]byte(key))
+		assert.True(t, ok)
+	}
+	assert.Nil(t, art.tree.root)
+}
@@ -0,0 +1,253 @@
+package index
+
+import (
+	"bitcask-go/data"
+	"bytes"
+	"go.etcd.io/bbolt"
+	"path/filepath"
+)
+
+const BPlusTreeIndexFileName = "bptree-index"
+
+var (
+	indexBucketName = []byte("bitcask-index")
+	metaBucketName  = []byte("bitcask-meta")
+	checkpointKey   = []byte("checkpoint")
+)
+
+// BPlusTree B+ 樹索引
+// 主要封裝了 go.etcd.io/bbolt 庫，索引存儲在磁盤上，不受內存大小的限制
+type BPlusTree struct {
+	tree *bbolt.DB
+}
+
+// NewBPlusTree 初始化 B+ 樹索引，索引文件無法打開或者已經損壞時返回錯誤
+func NewBPlusTree(dirPath string, syncWrites bool) (*BPlusTree, error) {
+	opts := bbolt.DefaultOptions
+	opts.NoSync = !syncWrites
+	bptree, err := bbolt.Open(filepath.Join(dirPath, BPlusTreeIndexFileName), 0644, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// 創建對應的 bucket
+	if err := bptree.Update(func(tx *bbolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(metaBucketName)
+		if err != nil {
+			return err
+		}
+		// 沒有保存加載位置的索引無法確定包含了哪些數據，清空之後從數據文件中重建
+		if meta.Get(checkpointKey) == nil && tx.Bucket(indexBucketName) != nil {
+			if err := tx.DeleteBucket(indexBucketName); err != nil {
+				return err
+			}
+		}
+		_, err = tx.CreateBucketIfNotExists(indexBucketName)
+		return err
+	}); err != nil {
+		_ = bptree.Close()
+		return nil, err
+	}
+	return &BPlusTree{tree: bptree}, nil
+}
+
+func (bpt *BPlusTree) Put(key []byte, pos *data.LogRecordPos) *data.LogRecordPos {
+	var oldPos *data.LogRecordPos
+	if err := bpt.tree.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(indexBucketName)
+		if value := bucket.Get(key); len(value) != 0 {
+			oldPos = data.DecodeLogRecordPos(value)
+		}
+		return bucket.Put(key, data.EncodeLogRecordPos(pos))
+	}); err != nil {
+		panic("failed to put value in bptree")
+	}
+	return oldPos
+}
+
+func (bpt *BPlusTree) Get(key []byte) *data.LogRecordPos {
+	var pos *data.LogRecordPos
+	if err := bpt.tree.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(indexBucketName)
+		value := bucket.Get(key)
+		if len(value) != 0 {
+			pos = data.DecodeLogRecordPos(value)
+		}
+		return nil
+	}); err != nil {
+		panic("failed to get value in bptree")
+	}
+	return pos
+}
+
+func (bpt *BPlusTree) Delete(key []byte) (*data.LogRecordPos, bool) {
+	var oldPos *data.LogRecordPos
+	if err := bpt.tree.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(indexBucketName)
+		if value := bucket.Get(key); len(value) != 0 {
+			oldPos = data.DecodeLogRecordPos(value)
+			return bucket.Delete(key)
+		}
+		return nil
+	}); err != nil {
+		panic("failed to delete value in bptree")
+	}
+	return oldPos, oldPos != nil
+}
+
+func (bpt *BPlusTree) Size() int {
+	var size int
+	if err := bpt.tree.View(func(tx *bbolt.Tx) error {
+		size = tx.Bucket(indexBucketName).Stats().KeyN
+		return nil
+	}); err != nil {
+		panic("failed to get size in bptree")
+	}
+	return size
+}
+
+func (bpt *BPlusTree) Iterator(reverse bool) Iterator {
+	return newBptreeIterator(bpt.tree, reverse)
+}
+
+// Checkpoint 返回索引已經包含的數據位置，這個位置之前的所有記錄都已經更新到了索引中
+// 打開數據庫時只需要從這個位置開始加載之後的記錄，沒有保存過時返回 nil
+func (bpt *BPlusTree) Checkpoint() *data.LogRecordPos {
+	var pos *data.LogRecordPos
+	if err := bpt.tree.View(func(tx *bbolt.Tx) error {
+		if value := tx.Bucket(metaBucketName).Get(checkpointKey); len(value) != 0 {
+			pos = data.DecodeLogRecordPos(value)
+		}
+		return nil
+	}); err != nil {
+		panic("failed to get checkpoint in bptree")
+	}
+	return pos
+}
+
+// SetCheckpoint 保存索引已經包含的數據位置，並將索引持久化到磁盤中
+func (bpt *BPlusTree) SetCheckpoint(pos *data.LogRecordPos) error {
+	if err := bpt.tree.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(metaBucketName).Put(checkpointKey, data.EncodeLogRecordPos(pos))
+	}); err != nil {
+		return err
+	}
+	return bpt.tree.Sync()
+}
+
+func (bpt *BPlusTree) Close() error {
+	return bpt.tree.Close()
+}
+
+// 每次從 B+ 樹中讀取到迭代器中的 key 的數量
+const bptreeIteratorBatchSize = 1024
+
+// B+ 樹迭代器
+// bbolt 的只讀事務存在時寫入可能因為擴展 mmap 而阻塞，迭代器不能長時間持有事務
+// 因此每次在一個短暫的只讀事務中讀取一批 key 和位置信息，讀取完之後再從上一批的最後一個 key 繼續讀取
+type bptreeIterator struct {
+	tree      *bbolt.DB
+	reverse   bool
+	items     []*Item // 當前批次的數據
+	currIndex int     // 當前遍歷到的位置
+	hasMore   bool    // 當前批次之後是否還有數據
+}
+
+func newBptreeIterator(tree *bbolt.DB, reverse bool) *bptreeIterator {
+	bpi := &bptreeIterator{
+		tree:    tree,
+		reverse: reverse,
+	}
+	bpi.Rewind()
+	return bpi
+}
+
+func (bpi *bptreeIterator) Rewind() {
+	bpi.load(func(cursor *bbolt.Cursor) ([]byte, []byte) {
+		if bpi.reverse {
+			return cursor.Last()
+		}
+		return cursor.First()
+	})
+}
+
+func (bpi *bptreeIterator) Seek(key []byte) {
+	bpi.load(func(cursor *bbolt.Cursor) ([]byte, []byte) {
+		return bptreeSeek(cursor, key, bpi.reverse)
+	})
+}
+
+func (bpi *bptreeIterator) Next() {
+	bpi.currIndex++
+	if bpi.currIndex < len(bpi.items) || !bpi.hasMore {
+		return
+	}
+	// 當前批次已經遍歷完，從最後一個 key 之後繼續讀取
+	lastKey := bpi.items[len(bpi.items)-1].key
+	bpi.load(func(cursor *bbolt.Cursor) ([]byte, []byte) {
+		k, v := bptreeSeek(cursor, lastKey, bpi.reverse)
+		if k != nil && bytes.Equal(k, lastKey) {
+			return bpi.move(cursor)
+		}
+		return k, v
+	})
+}
+
+func (bpi *bptreeIterator) Valid() bool {
+	return bpi.currIndex < len(bpi.items)
+}
+
+func (bpi *bptreeIterator) Key() []byte {
+	return bpi.items[bpi.currIndex].key
+}
+
+func (bpi *bptreeIterator) Value() *data.LogRecordPos {
+	return bpi.items[bpi.currIndex].pos
+}
+
+func (bpi *bptreeIterator) Close() {
+	bpi.items = nil
+}
+
+// load 在只讀事務中從 seek 返回的位置開始讀取一批數據
+// 索引已經關閉時讀取不到任何數據，迭代器變為無效
+func (bpi *bptreeIterator) load(seek func(cursor *bbolt.Cursor) ([]byte, []byte)) {
+	bpi.items = bpi.items[:0]
+	bpi.currIndex = 0
+	bpi.hasMore = false
+	_ = bpi.tree.View(func(tx *bbolt.Tx) error {
+		cursor := tx.Bucket(indexBucketName).Cursor()
+		k, v := seek(cursor)
+		for ; k != nil && len(bpi.items) < bptreeIteratorBatchSize; k, v = bpi.move(cursor) {
+			// 事務結束後 bbolt 返回的數據不再有效，需要複製出來
+			key := make([]byte, len(k))
+			copy(key, k)
+			bpi.items = append(bpi.items, &Item{key: key, pos: data.DecodeLogRecordPos(v)})
+		}
+		bpi.hasMore = k != nil
+		return nil
+	})
+}
+
+// move 按照遍歷的方向移動游標
+func (bpi *bptreeIterator) move(cursor *bbolt.Cursor) ([]byte, []byte) {
+	if bpi.reverse {
+		return cursor.Prev()
+	}
+	return cursor.Next()
+}
+
+// bptreeSeek 找到第一個大於等於 key 的位置，反向遍歷時找到第一個小於等於 key 的位置
+func bptreeSeek(cursor *bbolt.Cursor, key []byte, reverse bool) ([]byte, []byte) {
+	k, v := cursor.Seek(key)
+	if !reverse {
+		return k, v
+	}
+	if k == nil {
+		return cursor.Last()
+	}
+	if !bytes.Equal(k, key) {
+		return cursor.Prev()
+	}
+	return k, v
+}
@@ -0,0 +1,167 @@
+package index
+
+import (
+	"bitcask-go/data"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestBPlusTree_Put(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "bptree-put")
+	defer os.RemoveAll(dir)
+	tree, err := NewBPlusTree(dir, false)
+	assert.Nil(t, err)
+	defer tree.Close()
+
+	assert.Nil(t, tree.Put([]byte("aac"), &data.LogRecordPos{Fid: 123, Offset: 999}))
+	assert.Nil(t, tree.Put([]byte("abc"), &data.LogRecordPos{Fid: 123, Offset: 999}))
+	assert.Nil(t, tree.Put([]byte("acc"), &data.LogRecordPos{Fid: 123, Offset: 999}))
+
+	// 覆蓋已有的 key 時返回舊的位置信息
+	oldPos := tree.Put([]byte("acc"), &data.LogRecordPos{Fid: 124, Offset: 10, Size: 20})
+	assert.Equal(t, &data.LogRecordPos{Fid: 123, Offset: 999}, oldPos)
+	assert.Equal(t, 3, tree.Size())
+}
+
+func TestBPlusTree_Get(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "bptree-get")
+	defer os.RemoveAll(dir)
+	tree, err := NewBPlusTree(dir, false)
+	assert.Nil(t, err)
+	defer tree.Close()
+
+	assert.Nil(t, tree.Get([]byte("not exist")))
+
+	tree.Put([]byte("aac"), &data.LogRecordPos{Fid: 123, Offset: 999})
+	pos := tree.Get([]byte("aac"))
+	assert.Equal(t, uint32(123), pos.Fid)
+	assert.Equal(t, int64(999), pos.Offset)
+
+	tree.Put([]byte("aac"), &data.LogRecordPos{Fid: 9884, Offset: 1232})
+	pos = tree.Get([]byte("aac"))
+	assert.Equal(t, uint32(9884), pos.Fid)
+	assert.Equal(t, int64(1232), pos.Offset)
+}
+
+func TestBPlusTree_Delete(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "bptree-delete")
+	defer os.RemoveAll(dir)
+	tree, err := NewBPlusTree(dir, false)
+	assert.Nil(t, err)
+	defer tree.Close()
+
+	_, ok := tree.Delete([]byte("not exist"))
+	assert.False(t, ok)
+
+	tree.Put([]byte("aac"), &data.LogRecordPos{Fid: 123, Offset: 999, Size: 20})
+	oldPos, ok := tree.Delete([]byte("aac"))
+	assert.True(t, ok)
+	assert.Equal(t, &data.LogRecordPos{Fid: 123, Offset: 999, Size: 20}, oldPos)
+	assert.Nil(t, tree.Get([]byte("aac")))
+}
+
+func TestBPlusTree_Iterator(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "bptree-iter")
+	defer os.RemoveAll(dir)
+	tree, err := NewBPlusTree(dir, false)
+	assert.Nil(t, err)
+	defer tree.Close()
+
+	for _, key := range []string{"caed", "bbde", "acee", "bade"} {
+		tree.Put([]byte(key), &data.LogRecordPos{Fid: 1, Offset: 10})
+	}
+
+	iter := tree.Iterator(false)
+	var keys []string
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		keys = append(keys, string(iter.Key()))
+		assert.Equal(t, int64(10), iter.Value().Offset)
+	}
+	iter.Close()
+	assert.Equal(t, []string{"acee", "bade", "bbde", "caed"}, keys)
+
+	iter = tree.Iterator(true)
+	keys = nil
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		keys = append(keys, string(iter.Key()))
+	}
+	assert.Equal(t, []string{"caed", "bbde", "bade", "acee"}, keys)
+	iter.Seek([]byte("bb"))
+	assert.Equal(t, "bade", string(iter.Key()))
+	iter.Seek([]byte("zz"))
+	assert.Equal(t, "caed", string(iter.Key()))
+	iter.Close()
+
+	iter = tree.Iterator(false)
+	iter.Seek([]byte("bb"))
+	assert.Equal(t, "bbde", string(iter.Key()))
+	iter.Close()
+}
+
+func TestBPlusTree_IteratorBatches(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "bptree-iter-batches")
+	defer os.RemoveAll(dir)
+	tree, err := NewBPlusTree(dir, false)
+	assert.Nil(t, err)
+	defer tree.Close()
+
+	// key 的數量超過一個批次，遍歷時需要分多次讀取
+	n := bptreeIteratorBatchSize*2 + 10
+	for i := 0; i < n; i++ {
+		tree.Put([]byte(fmt.Sprintf("key-%06d", i)), &data.LogRecordPos{Fid: 1, Offset: int64(i)})
+	}
+
+	iter := tree.Iterator(false)
+	var count int
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		assert.Equal(t, fmt.Sprintf("key-%06d", count), string(iter.Key()))
+		assert.Equal(t, int64(count), iter.Value().Offset)
+		count++
+	}
+	iter.Close()
+	assert.Equal(t, n, count)
+
+	iter = tree.Iterator(true)
+	count = 0
+	for iter.Seek([]byte(fmt.Sprintf("key-%06d", n-100))); iter.Valid(); iter.Next() {
+		assert.Equal(t, fmt.Sprintf("key-%06d", n-100-count), string(iter.Key()))
+		count++
+	}
+	iter.Close()
+	assert.Equal(t, n-99, count)
+
+	// 遍歷過程中寫入的數據不會阻塞，也不會影響已經讀取的批次
+	iter = tree.Iterator(false)
+	tree.Put([]byte("key-000000"), &data.LogRecordPos{Fid: 2, Offset: 0})
+	assert.Equal(t, uint32(1), iter.Value().Fid)
+	iter.Close()
+}
+
+func TestBPlusTree_Checkpoint(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "bptree-checkpoint")
+	defer os.RemoveAll(dir)
+	tree, err := NewBPlusTree(dir, false)
+	assert.Nil(t, err)
+	assert.Nil(t, tree.Checkpoint())
+
+	// 沒有保存加載位置時，重新打開後索引被清空
+	tree.Put([]byte("aac"), &data.LogRecordPos{Fid: 1, Offset: 10})
+	assert.Nil(t, tree.Close())
+	tree, err = NewBPlusTree(dir, false)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, tree.Size())
+
+	// 保存加載位置之後，索引中的數據在重新打開後仍然有效
+	tree.Put([]byte("aac"), &data.LogRecordPos{Fid: 1, Offset: 10})
+	assert.Nil(t, tree.SetCheckpoint(&data.LogRecordPos{Fid: 2, Offset: 100}))
+	assert.Nil(t, tree.Close())
+	tree, err = NewBPlusTree(dir, false)
+	assert.Nil(t, err)
+	defer tree.Close()
+	assert.Equal(t, 1, tree.Size())
+	checkpoint := tree.Checkpoint()
+	assert.Equal(t, uint32(2), checkpoint.Fid)
+	assert.Equal(t, int64(100), checkpoint.Offset)
+}
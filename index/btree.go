@@ -21,21 +21,26 @@ func NewBTree() *BTree {
 	}
 }
 
-func (bt *BTree) Put(key []byte, pos *data.LogRecordPos) bool {
+func (bt *BTree) Put(key []byte, pos *data.LogRecordPos) *data.LogRecordPos {
 	it := &Item{key, pos}
 	bt.lock.Lock()
 
 	// 如果 key 不存在直接插入
 	// 如果 key 存在，則用新的 pos 替換舊的 pos
-	bt.tree.ReplaceOrInsert(it)
+	oldItem := bt.tree.ReplaceOrInsert(it)
 	bt.lock.Unlock()
-	return true
+	if oldItem == nil {
+		return nil
+	}
+	return oldItem.(*Item).pos
 }
 
 func (bt *BTree) Get(key []byte) *data.LogRecordPos {
 	it := &Item{key: key}
 
+	bt.lock.RLock()
 	btreeItem := bt.tree.Get(it)
+	bt.lock.RUnlock()
 	if btreeItem == nil {
 		return nil
 	}
@@ -44,7 +49,7 @@ func (bt *BTree) Get(key []byte) *data.LogRecordPos {
 	return btreeItem.(*Item).pos
 }
 
-func (bt *BTree) Delete(key []byte) bool {
+func (bt *BTree) Delete(key []byte) (*data.LogRecordPos, bool) {
 	it := &Item{
 		key: key,
 	}
@@ -54,9 +59,19 @@ func (bt *BTree) Delete(key []byte) bool {
 
 	// 刪除的 item 為空，則刪除失敗
 	if oldItem == nil {
-		return false
+		return nil, false
 	}
-	return true
+	return oldItem.(*Item).pos, true
+}
+
+func (bt *BTree) Size() int {
+	bt.lock.RLock()
+	defer bt.lock.RUnlock()
+	return bt.tree.Len()
+}
+
+func (bt *BTree) Close() error {
+	return nil
 }
 
 func (bt *BTree) Iterator(reverse bool) Iterator {
@@ -98,38 +113,38 @@ func newBTreeIterator(tree *btree.BTree, reverse bool) *bTreeIterator {
 	}
 }
 
-func (bti bTreeIterator) Rewind() {
+func (bti *bTreeIterator) Rewind() {
 	bti.curIndex = 0
 }
 
-func (bti bTreeIterator) Seek(key []byte) {
+func (bti *bTreeIterator) Seek(key []byte) {
 	if bti.reverse {
 		bti.curIndex = sort.Search(len(bti.values), func(i int) bool {
-			return bytes.Compare(bti.values[i].key, key) >= 0
+			return bytes.Compare(bti.values[i].key, key) <= 0
 		})
 	} else {
 		bti.curIndex = sort.Search(len(bti.values), func(i int) bool {
-			return bytes.Compare(bti.values[i].key, key) <= 0
+			return bytes.Compare(bti.values[i].key, key) >= 0
 		})
 	}
 }
 
-func (bti bTreeIterator) Next() {
+func (bti *bTreeIterator) Next() {
 	bti.curIndex++
 }
 
-func (bti bTreeIterator) Valid() bool {
+func (bti *bTreeIterator) Valid() bool {
 	return bti.curIndex < len(bti.values)
 }
 
-func (bti bTreeIterator) Key() []byte {
+func (bti *bTreeIterator) Key() []byte {
 	return bti.values[bti.curIndex].key
 }
 
-func (bti bTreeIterator) Value() *data.LogRecordPos {
+func (bti *bTreeIterator) Value() *data.LogRecordPos {
 	return bti.values[bti.curIndex].pos
 }
 
-func (bti bTreeIterator) Close() {
+func (bti *bTreeIterator) Close() {
 	bti.values = nil
 }
@@ -10,10 +10,16 @@ func TestBTree_Put(t *testing.T) {
 	tree := NewBTree()
 
 	res1 := tree.Put(nil, &data.LogRecordPos{Fid: 1, Offset: 100})
+	assert.Nil(t, res1)
 
-	assert.True(t, res1)
 	res2 := tree.Put([]byte("assert"), &data.LogRecordPos{Fid: 1, Offset: 50})
-	assert.True(t, res2)
+	assert.Nil(t, res2)
+
+	// 覆蓋已有的 key 時返回舊的位置信息
+	res3 := tree.Put([]byte("assert"), &data.LogRecordPos{Fid: 11, Offset: 12})
+	assert.Equal(t, uint32(1), res3.Fid)
+	assert.Equal(t, int64(50), res3.Offset)
+	assert.Equal(t, 2, tree.Size())
 }
 
 func TestBTree_Get(t *testing.T) {
@@ -25,7 +31,7 @@ func TestBTree_Get(t *testing.T) {
 	assert.Equal(t, uint32(1), pos1.Fid)
 
 	res2 := tree.Put([]byte("assert"), &data.LogRecordPos{Fid: 1, Offset: 50})
-	assert.True(t, res2)
+	assert.Nil(t, res2)
 
 	pos2 := tree.Get([]byte("assert"))
 
@@ -42,15 +48,58 @@ func TestBTree_Delete(t *testing.T) {
 	assert.Equal(t, uint32(1), pos1.Fid)
 
 	res2 := tree.Put([]byte("assert"), &data.LogRecordPos{Fid: 1, Offset: 50})
-	assert.True(t, res2)
+	assert.Nil(t, res2)
 
 	pos2 := tree.Get([]byte("assert"))
 	assert.Equal(t, pos2.Fid, uint32(1))
 	assert.Equal(t, pos2.Offset, int64(50))
 
-	res4 := tree.Delete(nil)
-	assert.True(t, res4)
+	res4, ok4 := tree.Delete(nil)
+	assert.True(t, ok4)
+	assert.Equal(t, int64(100), res4.Offset)
+
+	res5, ok5 := tree.Delete([]byte("assert"))
+	assert.True(t, ok5)
+	assert.Equal(t, int64(50), res5.Offset)
+
+	res6, ok6 := tree.Delete([]byte("assert"))
+	assert.False(t, ok6)
+	assert.Nil(t, res6)
+	assert.Equal(t, 0, tree.Size())
+}
 
-	res5 := tree.Delete([]byte("assert"))
-	assert.True(t, res5)
+func TestBTree_Iterator(t *testing.T) {
+	bt1 := NewBTree()
+	// BTree 為空的情況
+	iter1 := bt1.Iterator(false)
+	assert.Equal(t, false, iter1.Valid())
+
+	// BTree 有數據的情況
+	bt1.Put([]byte("ccde"), &data.LogRecordPos{Fid: 1, Offset: 10})
+	bt1.Put([]byte("acee"), &data.LogRecordPos{Fid: 1, Offset: 10})
+	bt1.Put([]byte("eede"), &data.LogRecordPos{Fid: 1, Offset: 10})
+	bt1.Put([]byte("bbcd"), &data.LogRecordPos{Fid: 1, Offset: 10})
+
+	iter2 := bt1.Iterator(false)
+	var keys []string
+	for iter2.Rewind(); iter2.Valid(); iter2.Next() {
+		keys = append(keys, string(iter2.Key()))
+	}
+	assert.Equal(t, []string{"acee", "bbcd", "ccde", "eede"}, keys)
+
+	iter3 := bt1.Iterator(true)
+	keys = nil
+	for iter3.Rewind(); iter3.Valid(); iter3.Next() {
+		keys = append(keys, string(iter3.Key()))
+	}
+	assert.Equal(t, []string{"eede", "ccde", "bbcd", "acee"}, keys)
+
+	// 測試 Seek
+	iter4 := bt1.Iterator(false)
+	iter4.Seek([]byte("cc"))
+	assert.Equal(t, "ccde", string(iter4.Key()))
+
+	iter5 := bt1.Iterator(true)
+	iter5.Seek([]byte("cc"))
+	assert.Equal(t, "bbcd", string(iter5.Key()))
 }
@@ -3,22 +3,30 @@ package index
 import (
 	"bitcask-go/data"
 	"bytes"
+	"errors"
 	"github.com/google/btree"
+	"go.etcd.io/bbolt"
 )
 
 // Indexer 抽象索引接口
 type Indexer interface {
-	// Put 向索引中存儲 key 對應的數據位置信息
-	Put(key []byte, pos *data.LogRecordPos) bool
+	// Put 向索引中存儲 key 對應的數據位置信息，返回被替換掉的舊的位置信息，key 之前不存在時返回 nil
+	Put(key []byte, pos *data.LogRecordPos) *data.LogRecordPos
 
 	// Get 根據 key 取出對應的索引位置信息
 	Get(key []byte) *data.LogRecordPos
 
-	// Delete 根據 key 刪除對應的索引位置信息
-	Delete(key []byte) bool
+	// Delete 根據 key 刪除對應的索引位置信息，返回被刪除的位置信息以及 key 是否存在
+	Delete(key []byte) (*data.LogRecordPos, bool)
+
+	// Size 索引中 key 的數量
+	Size() int
 
 	// Iterator 返回索引迭代器
 	Iterator(reverse bool) Iterator
+
+	// Close 關閉索引
+	Close() error
 }
 
 type IndexType = int8
@@ -29,19 +37,33 @@ const (
 
 	// ART 自適應基數樹索引
 	ART
+
+	// BPTree B+ 樹索引
+	BPTree
 )
 
+// MaxKeySize 索引支持的 key 的最大長度，受限於 B+ 樹索引
+// 所有類型的索引使用相同的限制，保證數據目錄可以切換索引類型後重建索引
+const MaxKeySize = bbolt.MaxKeySize
+
 // NewIndexer 根據類型初始化索引
-func NewIndexer(typ IndexType) Indexer {
+func NewIndexer(typ IndexType, dirPath string, sync bool) (Indexer, error) {
 	switch typ {
 	case Btree:
-		return NewBTree()
+		return NewBTree(), nil
 
 	case ART:
-		// TODO
-		return nil
+		return NewART(), nil
+
+	case BPTree:
+		// 打開失敗時不能返回包含空指針的接口
+		bptree, err := NewBPlusTree(dirPath, sync)
+		if err != nil {
+			return nil, err
+		}
+		return bptree, nil
 	default:
-		panic("unsupported index type")
+		return nil, errors.New("unsupported index type")
 	}
 }
 
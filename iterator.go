@@ -0,0 +1,155 @@
+package bitcask_go
+
+import (
+	"bitcask-go/index"
+	"bytes"
+	"time"
+)
+
+// Iterator 面向用戶的迭代器
+// 已經過期但還沒有從索引中清理的 key 會被跳過
+type Iterator struct {
+	indexIter index.Iterator // 索引迭代器
+	db        *DB
+	options   IteratorOptions
+}
+
+// NewIterator 初始化迭代器，數據庫已經關閉時返回一個無效的迭代器
+func (db *DB) NewIterator(opts IteratorOptions) *Iterator {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.closed {
+		return db.newIterator(index.NewBTree().Iterator(opts.Reverse), opts)
+	}
+	return db.newIterator(db.index.Iterator(opts.Reverse), opts)
+}
+
+// newIterator 基於指定的索引迭代器初始化迭代器
+func (db *DB) newIterator(indexIter index.Iterator, opts IteratorOptions) *Iterator {
+	it := &Iterator{
+		indexIter: indexIter,
+		db:        db,
+		options:   opts,
+	}
+	it.Rewind()
+	return it
+}
+
+// Rewind 重新回到迭代器的起點，即第一個數據
+func (it *Iterator) Rewind() {
+	it.indexIter.Rewind()
+	if !it.options.Reverse {
+		// 正向遍歷時可以直接跳到前綴與 Start 中較大的位置
+		seekKey := it.options.Start
+		if bytes.Compare(it.options.Prefix, seekKey) > 0 {
+			seekKey = it.options.Prefix
+		}
+		if len(seekKey) > 0 {
+			it.indexIter.Seek(seekKey)
+		}
+	} else {
+		// 反向遍歷時從前綴的上界與 End 中較小的位置開始
+		seekKey := it.options.End
+		if upper := prefixUpperBound(it.options.Prefix); upper != nil && (len(seekKey) == 0 || bytes.Compare(upper, seekKey) < 0) {
+			seekKey = upper
+		}
+		if len(seekKey) > 0 {
+			it.indexIter.Seek(seekKey)
+		}
+	}
+	it.skipToNext()
+}
+
+// Seek 根據傳入的 key 查找到第一個大於(或小於)等於的目標 key，從這個 key 開始遍歷
+func (it *Iterator) Seek(key []byte) {
+	it.indexIter.Seek(key)
+	it.skipToNext()
+}
+
+// Next 跳轉到下一個 key
+func (it *Iterator) Next() {
+	it.indexIter.Next()
+	it.skipToNext()
+}
+
+// Valid 是否有效，即是否已經遍歷完了所有的 key，用於退出遍歷
+func (it *Iterator) Valid() bool {
+	return it.indexIter.Valid() && !it.pastRange(it.indexIter.Key())
+}
+
+// Key 當前遍歷位置的 Key 數據
+func (it *Iterator) Key() []byte {
+	return it.indexIter.Key()
+}
+
+// Value 當前遍歷位置的 Value 數據
+func (it *Iterator) Value() ([]byte, error) {
+	logRecordPos := it.indexIter.Value()
+	it.db.mu.RLock()
+	defer it.db.mu.RUnlock()
+	if it.db.closed {
+		return nil, ErrDatabaseClosed
+	}
+	return it.db.getValueByPosition(logRecordPos)
+}
+
+// Close 關閉迭代器，釋放相應資源
+func (it *Iterator) Close() {
+	it.indexIter.Close()
+}
+
+// skipToNext 跳過不符合前綴以及範圍條件的 key，以及已經過期但還沒有從索引中清理的 key
+// 超出遍歷範圍之後直接停止，不再繼續讀取之後的 key
+func (it *Iterator) skipToNext() {
+	prefixLen := len(it.options.Prefix)
+	now := time.Now().UnixNano()
+	for ; it.indexIter.Valid(); it.indexIter.Next() {
+		key := it.indexIter.Key()
+		if it.pastRange(key) {
+			return
+		}
+		if prefixLen > 0 && !bytes.HasPrefix(key, it.options.Prefix) {
+			continue
+		}
+		if !it.options.Reverse && len(it.options.Start) > 0 && bytes.Compare(key, it.options.Start) < 0 {
+			continue
+		}
+		if it.options.Reverse && len(it.options.End) > 0 && bytes.Compare(key, it.options.End) >= 0 {
+			continue
+		}
+		if it.indexIter.Value().IsExpired(now) {
+			continue
+		}
+		break
+	}
+}
+
+// pastRange 判斷 key 是否已經超出了遍歷範圍，超出之後按照遍歷的方向後續的 key 都不會再符合條件
+func (it *Iterator) pastRange(key []byte) bool {
+	prefix := it.options.Prefix
+	if it.options.Reverse {
+		if len(it.options.Start) > 0 && bytes.Compare(key, it.options.Start) < 0 {
+			return true
+		}
+		// 比前綴小的 key 不可能以前綴開頭
+		return len(prefix) > 0 && bytes.Compare(key, prefix) < 0
+	}
+	if len(it.options.End) > 0 && bytes.Compare(key, it.options.End) >= 0 {
+		return true
+	}
+	return len(prefix) > 0 && bytes.Compare(key, prefix) > 0 && !bytes.HasPrefix(key, prefix)
+}
+
+// prefixUpperBound 返回大於所有以 prefix 開頭的 key 的最小值，不存在時返回 nil
+// 例如 "ab" 的上界為 "ac"，"a\xff" 的上界為 "b"
+func prefixUpperBound(prefix []byte) []byte {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] < 0xff {
+			upper := make([]byte, i+1)
+			copy(upper, prefix)
+			upper[i]++
+			return upper
+		}
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package bitcask_go
+
+import (
+	"bitcask-go/index"
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDB_NewIterator(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-iterator")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	// 數據庫為空
+	iter := db.NewIterator(DefaultIteratorOptions)
+	assert.False(t, iter.Valid())
+	iter.Close()
+
+	keys := []string{"aacd", "aaef", "bbac", "bbcd", "bbed", "ccde"}
+	for _, key := range keys {
+		err := db.Put([]byte(key), []byte("value-"+key))
+		assert.Nil(t, err)
+	}
+
+	// 正向遍歷，並讀取 value
+	iter = db.NewIterator(DefaultIteratorOptions)
+	var got []string
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		val, err := iter.Value()
+		assert.Nil(t, err)
+		assert.Equal(t, "value-"+string(iter.Key()), string(val))
+		got = append(got, string(iter.Key()))
+	}
+	iter.Close()
+	assert.Equal(t, keys, got)
+
+	// 反向遍歷
+	iter = db.NewIterator(IteratorOptions{Reverse: true})
+	got = nil
+	for ; iter.Valid(); iter.Next() {
+		got = append(got, string(iter.Key()))
+	}
+	iter.Close()
+	assert.Equal(t, []string{"ccde", "bbed", "bbcd", "bbac", "aaef", "aacd"}, got)
+
+	// Seek
+	iter = db.NewIterator(DefaultIteratorOptions)
+	iter.Seek([]byte("bb"))
+	assert.Equal(t, "bbac", string(iter.Key()))
+	iter.Close()
+
+	// 指定前綴
+	iter = db.NewIterator(IteratorOptions{Prefix: []byte("bb")})
+	got = nil
+	for ; iter.Valid(); iter.Next() {
+		got = append(got, string(iter.Key()))
+	}
+	iter.Close()
+	assert.Equal(t, []string{"bbac", "bbcd", "bbed"}, got)
+
+	// 指定前綴反向遍歷
+	iter = db.NewIterator(IteratorOptions{Prefix: []byte("aa"), Reverse: true})
+	got = nil
+	for ; iter.Valid(); iter.Next() {
+		got = append(got, string(iter.Key()))
+	}
+	iter.Close()
+	assert.Equal(t, []string{"aaef", "aacd"}, got)
+
+	// 指定範圍 [Start, End)
+	iter = db.NewIterator(IteratorOptions{Start: []byte("aaef"), End: []byte("bbed")})
+	got = nil
+	for ; iter.Valid(); iter.Next() {
+		got = append(got, string(iter.Key()))
+	}
+	iter.Close()
+	assert.Equal(t, []string{"aaef", "bbac", "bbcd"}, got)
+
+	// 指定範圍反向遍歷
+	iter = db.NewIterator(IteratorOptions{Start: []byte("aaf"), End: []byte("bbed"), Reverse: true})
+	got = nil
+	for ; iter.Valid(); iter.Next() {
+		got = append(got, string(iter.Key()))
+	}
+	iter.Close()
+	assert.Equal(t, []string{"bbcd", "bbac"}, got)
+}
+
+// countingIterator 統計索引迭代器移動的次數
+type countingIterator struct {
+	index.Iterator
+	moves *int
+}
+
+func (ci *countingIterator) Next() {
+	*ci.moves++
+	ci.Iterator.Next()
+}
+
+// 前綴遍歷在超出前綴範圍之後停止，不會讀取之後所有的 key
+func TestDB_IteratorPrefixStopsEarly(t *testing.T) {
+	for _, indexType := range []IndexerType{Btree, ART, BPlusTree} {
+		opts := DefaultOptions
+		dir, _ := os.MkdirTemp("", "bitcask-go-iterator-prefix")
+		opts.DirPath = dir
+		opts.IndexType = indexType
+		db, err := Open(opts)
+		assert.Nil(t, err)
+		for _, prefix := range []string{"a", "b", "c"} {
+			for i := 0; i < 100; i++ {
+				assert.Nil(t, db.Put([]byte(fmt.Sprintf("%s-%03d", prefix, i)), []byte("value")))
+			}
+		}
+
+		for _, reverse := range []bool{false, true} {
+			moves := 0
+			indexIter := &countingIterator{Iterator: db.index.Iterator(reverse), moves: &moves}
+			iter := db.newIterator(indexIter, IteratorOptions{Prefix: []byte("b"), Reverse: reverse})
+			count := 0
+			for ; iter.Valid(); iter.Next() {
+				assert.True(t, bytes.HasPrefix(iter.Key(), []byte("b")))
+				count++
+			}
+			iter.Close()
+			assert.Equal(t, 100, count)
+			assert.Equal(t, 100, moves)
+		}
+
+		// 前綴的最後一個字節為 0xff 時同樣可以反向遍歷
+		assert.Nil(t, db.Put([]byte{'b', 0xff, 1}, []byte("value")))
+		iter := db.NewIterator(IteratorOptions{Prefix: []byte{'b', 0xff}, Reverse: true})
+		assert.True(t, iter.Valid())
+		assert.Equal(t, []byte{'b', 0xff, 1}, iter.Key())
+		iter.Next()
+		assert.False(t, iter.Valid())
+		iter.Close()
+		destroyDB(db)
+	}
+}
+
+// B+ 樹索引的迭代器讀取 value 時，並發的寫入不會因為 bbolt 擴展 mmap 而死鎖
+func TestDB_IteratorBPlusTreeConcurrentPut(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-iterator-bptree")
+	opts.DirPath = dir
+	opts.IndexType = BPlusTree
+	db, err := Open(opts)
+	assert.Nil(t, err)
+
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, db.Put(getTestKey(i), []byte("value")))
+	}
+
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		iter := db.NewIterator(DefaultIteratorOptions)
+		defer iter.Close()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if !iter.Valid() {
+				iter.Rewind()
+			}
+			_, err := iter.Value()
+			assert.Nil(t, err)
+			iter.Next()
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 100; i < 2000; i++ {
+			assert.Nil(t, db.Put(getTestKey(i), []byte("value")))
+		}
+	}()
+
+	// 死鎖時無法關閉數據庫，直接結束測試
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("put is blocked by the iterator")
+	}
+	close(stop)
+	<-stopped
+	destroyDB(db)
+}
+
+// 已經過期但還沒有從索引中清理的 key 不會被遍歷到
+func TestDB_IteratorSkipExpired(t *testing.T) {
+	for _, indexType := range []IndexerType{Btree, ART, BPlusTree} {
+		opts := DefaultOptions
+		dir, _ := os.MkdirTemp("", "bitcask-go-iterator-ttl")
+		opts.DirPath = dir
+		opts.IndexType = indexType
+		db, err := Open(opts)
+		assert.Nil(t, err)
+
+		assert.Nil(t, db.Put(getTestKey(1), []byte("value-1")))
+		assert.Nil(t, db.PutWithTTL(getTestKey(2), []byte("value-2"), 50*time.Millisecond))
+		assert.Nil(t, db.PutWithTTL(getTestKey(3), []byte("value-3"), time.Hour))
+		time.Sleep(100 * time.Millisecond)
+
+		var keys [][]byte
+		iter := db.NewIterator(DefaultIteratorOptions)
+		for iter.Rewind(); iter.Valid(); iter.Next() {
+			_, err := iter.Value()
+			assert.Nil(t, err)
+			keys = append(keys, iter.Key())
+		}
+		iter.Close()
+		assert.Equal(t, [][]byte{getTestKey(1), getTestKey(3)}, keys)
+
+		iter = db.NewIterator(IteratorOptions{Reverse: true})
+		iter.Seek(getTestKey(2))
+		assert.Equal(t, getTestKey(1), iter.Key())
+		iter.Close()
+		destroyDB(db)
+	}
+}
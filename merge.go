@@ -0,0 +1,418 @@
+package bitcask_go
+
+import (
+	"bitcask-go/data"
+	"bitcask-go/index"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	mergeDirName     = "-merge"
+	mergeFinishedKey = "merge.finished"
+)
+
+// Merge 清理無效數據，將舊數據文件中仍然有效的記錄重寫到 merge 目錄中
+// merge 的結果會在下一次打開數據庫時替換原有的數據文件
+func (db *DB) Merge() error {
+	db.mu.Lock()
+	if db.closed {
+		db.mu.Unlock()
+		return ErrDatabaseClosed
+	}
+	// 如果數據庫為空，則直接返回
+	if db.activeFile == nil {
+		db.mu.Unlock()
+		return nil
+	}
+	// 如果 merge 正在進行當中，則直接返回
+	if db.isMerging {
+		db.mu.Unlock()
+		return ErrMergeIsProgress
+	}
+	// merge 需要將有效數據重寫一遍，確保磁盤上有足夠的空間
+	if err := db.checkMergeSpace(); err != nil {
+		db.mu.Unlock()
+		return err
+	}
+	db.isMerging = true
+	db.mergeWg.Add(1)
+	defer func() {
+		db.mu.Lock()
+		db.isMerging = false
+		db.mu.Unlock()
+		db.mergeWg.Done()
+	}()
+
+	// 持久化當前活躍文件
+	if err := db.activeFile.Sync(); err != nil {
+		db.mu.Unlock()
+		return err
+	}
+	// merge 後的文件 id 從 0 開始，不能超過新的活躍文件的 id
+	mergeFileLimit, err := db.mergeFileLimit()
+	if err != nil {
+		db.mu.Unlock()
+		return err
+	}
+	// 將當前活躍文件轉換為舊的數據文件
+	db.olderFiles[db.activeFile.FileId] = db.activeFile
+	// 打開新的活躍文件，之後的寫入都不會參與這次 merge
+	activeFileId := max(db.activeFile.FileId+1, mergeFileLimit)
+	if err := db.openActiveDataFile(activeFileId); err != nil {
+		db.mu.Unlock()
+		return err
+	}
+	// 記錄最近沒有參與 merge 的文件 id
+	nonMergeFileId := db.activeFile.FileId
+	// 記錄當前的序列號，參與 merge 的記錄的序列號都不會超過它
+	// merge 後的文件從 hint 文件加載索引，打開時需要從這裡恢復序列號
+	seqNo := db.seqNo
+	// 記錄本次 merge 能夠清理的無效數據
+	reclaimSize := db.reclaimSize
+
+	// 取出所有需要 merge 的文件
+	var mergeFiles []*data.DataFile
+	for _, file := range db.olderFiles {
+		mergeFiles = append(mergeFiles, file)
+	}
+	db.mu.Unlock()
+
+	// 待 merge 的文件從小到大進行排序，依次 merge
+	sort.Slice(mergeFiles, func(i, j int) bool {
+		return mergeFiles[i].FileId < mergeFiles[j].FileId
+	})
+
+	mergePath := db.getMergePath()
+	// 如果目錄存在，說明之前發生過 merge 但沒有完成，將其刪掉
+	if _, err := os.Stat(mergePath); err == nil {
+		if err := os.RemoveAll(mergePath); err != nil {
+			return err
+		}
+	}
+	// 新建一個 merge path 的目錄
+	if err := os.MkdirAll(mergePath, os.ModePerm); err != nil {
+		return err
+	}
+
+	// 打開一個新的臨時 bitcask 實例
+	mergeOptions := db.options
+	mergeOptions.DirPath = mergePath
+	mergeOptions.SyncWrites = false
+	// 臨時實例只用於寫數據，不需要使用持久化的索引，也不需要自動 merge
+	mergeOptions.IndexType = Btree
+	mergeOptions.MergeInterval = 0
+	mergeDB, err := Open(mergeOptions)
+	if err != nil {
+		return err
+	}
+	defer mergeDB.Close()
+
+	// 打開 hint 文件，存儲 merge 後記錄的索引
+	hintFile, err := data.OpenHintFile(mergePath)
+	if err != nil {
+		return err
+	}
+	hintFile.Cipher = db.cipher
+	defer hintFile.Close()
+
+	// 遍歷處理每個數據文件
+	now := time.Now().UnixNano()
+	for _, dataFile := range mergeFiles {
+		offset := dataFile.HeaderSize()
+		for {
+			logRecord, size, err := dataFile.ReadLogRecord(offset)
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				return err
+			}
+			// 解析拿到實際的 key
+			realKey, seqNo, _ := ParseLogRecordKey(logRecord.Key)
+			// 和內存中的索引位置進行比較，如果有效則重寫
+			logRecordPos := db.index.Get(realKey)
+			// 已經過期的數據不再重寫
+			if logRecordPos != nil && !logRecord.IsExpired(now) &&
+				logRecordPos.Fid == dataFile.FileId && logRecordPos.Offset == offset {
+				// 保留原有的序列號並清除批量寫入標記，有效的記錄一定是已經提交的
+				logRecord.Key = logRecordKeyWithSeq(realKey, seqNo, false)
+				pos, err := mergeDB.appendLogRecord(logRecord)
+				if err != nil {
+					return err
+				}
+				// 加載時 merge 後的文件會覆蓋同 id 的文件，不能覆蓋到沒有參與 merge 的文件
+				if pos.Fid >= nonMergeFileId {
+					return ErrMergeFileIdsExhausted
+				}
+				// 將當前位置索引寫到 hint 文件中
+				if err := hintFile.WriteHintRecord(realKey, pos, logRecord.Expiry); err != nil {
+					return err
+				}
+			}
+			// 增加 offset
+			offset += size
+		}
+	}
+
+	// 確保 merge 目錄中至少有一個數據文件，加載時以此判斷哪些文件已經被替換
+	if mergeDB.activeFile == nil {
+		if err := mergeDB.setActiveDataFile(); err != nil {
+			return err
+		}
+	}
+	if err := mergeDB.activeFile.Sync(); err != nil {
+		return err
+	}
+	if err := hintFile.Sync(); err != nil {
+		return err
+	}
+
+	// 寫標識 merge 完成的文件
+	mergeFinishedFile, err := data.OpenMergeFinishedFile(mergePath)
+	if err != nil {
+		return err
+	}
+	defer mergeFinishedFile.Close()
+	for _, record := range []*data.LogRecord{
+		{Key: []byte(mergeFinishedKey), Value: []byte(strconv.Itoa(int(nonMergeFileId)))},
+		{Key: []byte(seqNoKey), Value: []byte(strconv.FormatUint(seqNo, 10))},
+	} {
+		encRecord, _ := data.EncodeLogRecord(record)
+		if err := mergeFinishedFile.Write(encRecord); err != nil {
+			return err
+		}
+	}
+	if err := mergeFinishedFile.Sync(); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	db.mergedSize = reclaimSize
+	db.mu.Unlock()
+	return nil
+}
+
+// mergeFileLimit 計算 merge 後最多需要的數據文件數量
+// 有效記錄的數量不超過索引中 key 的數量，重寫時每條記錄最多增加加密的開銷，由此得到 merge 後數據總量的上限
+// 只有當前文件放不下下一條記錄時才會切換文件，相鄰兩個文件中的記錄加起來一定超過一個文件的容量
+// 在訪問此方法前必須持有鎖
+func (db *DB) mergeFileLimit() (uint32, error) {
+	totalSize, err := db.dataFilesSize()
+	if err != nil {
+		return 0, err
+	}
+	keyNum := int64(db.index.Size())
+	totalSize += keyNum * data.MaxEncryptionOverhead
+
+	// 每個文件中至少有一條記錄，沒有記錄時也會生成一個文件
+	limit := max(keyNum, 1)
+	if capacity := db.options.DataFileSize - data.FileHeaderSize; capacity > 0 {
+		limit = min(limit, 2*(totalSize/capacity)+1)
+	}
+	return uint32(min(limit, math.MaxUint32)), nil
+}
+
+// checkMergeSpace 檢查磁盤剩餘空間是否足夠存放 merge 後的數據
+// 在訪問此方法前必須持有鎖
+func (db *DB) checkMergeSpace() error {
+	totalSize, err := db.dataFilesSize()
+	if err != nil {
+		return err
+	}
+	availableSize, err := availableDiskSize(db.options.DirPath)
+	if err != nil {
+		return err
+	}
+	if totalSize-db.reclaimSize > int64(availableSize) {
+		return ErrNoEnoughSpaceForMerge
+	}
+	return nil
+}
+
+// dataFilesSize 所有數據文件的總大小
+// 在訪問此方法前必須持有鎖
+func (db *DB) dataFilesSize() (int64, error) {
+	var size int64
+	if db.activeFile != nil {
+		size += db.activeFile.WriteOffset
+	}
+	for _, file := range db.olderFiles {
+		fileSize, err := file.IOManager.Size()
+		if err != nil {
+			return 0, err
+		}
+		size += fileSize
+	}
+	return size, nil
+}
+
+// getMergePath 獲取 merge 目錄，與數據目錄同級
+// 例如數據目錄為 /tmp/bitcask，則 merge 目錄為 /tmp/bitcask-merge
+func (db *DB) getMergePath() string {
+	dir := filepath.Dir(filepath.Clean(db.options.DirPath))
+	base := filepath.Base(db.options.DirPath)
+	return filepath.Join(dir, base+mergeDirName)
+}
+
+// loadMergeFiles 加載 merge 數據目錄，用 merge 後的文件替換原有的數據文件
+// 替換失敗時保留 merge 目錄，下次打開時從中斷的位置繼續替換
+func (db *DB) loadMergeFiles() error {
+	mergePath := db.getMergePath()
+	// merge 目錄不存在的話直接返回
+	if _, err := os.Stat(mergePath); os.IsNotExist(err) {
+		return nil
+	}
+
+	dirEntries, err := os.ReadDir(mergePath)
+	if err != nil {
+		return err
+	}
+
+	// 查找標識 merge 完成的文件，判斷 merge 是否處理完了
+	var mergeFinished bool
+	var mergeFileIds []int
+	for _, entry := range dirEntries {
+		if entry.Name() == data.MergeFinishedFileName {
+			mergeFinished = true
+		}
+		if strings.HasSuffix(entry.Name(), data.FileNameSuffix) {
+			fileId, err := strconv.Atoi(strings.Split(entry.Name(), ".")[0])
+			if err != nil {
+				return ErrDataDirectoryCorrupted
+			}
+			mergeFileIds = append(mergeFileIds, fileId)
+		}
+	}
+
+	// 沒有 merge 完成則刪除 merge 目錄後直接返回
+	if !mergeFinished {
+		return os.RemoveAll(mergePath)
+	}
+
+	nonMergeFileId, _, err := db.readMergeFinishedFile(mergePath)
+	if err != nil {
+		return err
+	}
+
+	// B+ 樹索引中的位置信息在替換數據文件後會失效，刪除後重新構建
+	bptreeIndexFileName := filepath.Join(db.options.DirPath, index.BPlusTreeIndexFileName)
+	if err := os.Remove(bptreeIndexFileName); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// merge 後的文件 id 是從 0 開始連續遞增的，且按從小到大的順序移動
+	// 因此目錄中剩餘的最大 id 即為 merge 文件的數量，可以在中途崩潰後重複執行
+	if len(mergeFileIds) > 0 {
+		sort.Ints(mergeFileIds)
+		mergeFileNum := uint32(mergeFileIds[len(mergeFileIds)-1] + 1)
+
+		// 刪除沒有被 merge 文件覆蓋的舊數據文件
+		for fileId := mergeFileNum; fileId < nonMergeFileId; fileId++ {
+			fileName := data.GetDataFileName(db.options.DirPath, fileId)
+			if err := os.Remove(fileName); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+
+		// 將 merge 後的文件移動到數據目錄中，覆蓋同 id 的舊文件
+		for _, fileId := range mergeFileIds {
+			srcPath := data.GetDataFileName(mergePath, uint32(fileId))
+			destPath := data.GetDataFileName(db.options.DirPath, uint32(fileId))
+			if err := os.Rename(srcPath, destPath); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 移動 hint 文件，最後移動標識 merge 完成的文件
+	for _, fileName := range []string{data.HintFileName, data.MergeFinishedFileName} {
+		srcPath := filepath.Join(mergePath, fileName)
+		if _, err := os.Stat(srcPath); os.IsNotExist(err) {
+			continue
+		}
+		destPath := filepath.Join(db.options.DirPath, fileName)
+		if err := os.Rename(srcPath, destPath); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(mergePath)
+}
+
+// readMergeFinishedFile 從標識 merge 完成的文件中讀取最近沒有參與 merge 的文件 id 以及 merge 時的序列號
+// 舊版本的文件中沒有保存序列號，此時返回的序列號為 0
+func (db *DB) readMergeFinishedFile(dirPath string) (uint32, uint64, error) {
+	mergeFinishedFile, err := data.OpenMergeFinishedFile(dirPath)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer mergeFinishedFile.Close()
+	record, size, err := mergeFinishedFile.ReadLogRecord(0)
+	if err != nil {
+		return 0, 0, err
+	}
+	nonMergeFileId, err := strconv.Atoi(string(record.Value))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	record, _, err = mergeFinishedFile.ReadLogRecord(size)
+	if err == io.EOF {
+		return uint32(nonMergeFileId), 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	seqNo, err := strconv.ParseUint(string(record.Value), 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return uint32(nonMergeFileId), seqNo, nil
+}
+
+// loadIndexFromHintFile 從 hint 文件中加載索引
+func (db *DB) loadIndexFromHintFile() error {
+	// 查看 hint 索引文件是否存在
+	hintFileName := filepath.Join(db.options.DirPath, data.HintFileName)
+	if _, err := os.Stat(hintFileName); os.IsNotExist(err) {
+		return nil
+	}
+
+	// 打開 hint 索引文件
+	hintFile, err := data.OpenHintFile(db.options.DirPath)
+	if err != nil {
+		return err
+	}
+	hintFile.Cipher = db.cipher
+	defer hintFile.Close()
+
+	// 讀取文件中的索引
+	now := time.Now().UnixNano()
+	var offset int64 = 0
+	for {
+		logRecord, size, err := hintFile.ReadLogRecord(offset)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+
+		// 解碼拿到實際的位置索引，已經過期的數據不加載到索引中
+		pos := data.DecodeLogRecordPos(logRecord.Value)
+		pos.Expiry = logRecord.Expiry
+		if logRecord.IsExpired(now) {
+			db.reclaimSize += int64(pos.Size)
+		} else if oldPos := db.index.Put(logRecord.Key, pos); oldPos != nil {
+			db.reclaimSize += int64(oldPos.Size)
+		}
+		offset += size
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package bitcask_go
+
+import (
+	"bitcask-go/data"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 沒有任何數據的情況下進行 merge
+func TestDB_Merge(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-merge-1")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	err = db.Merge()
+	assert.Nil(t, err)
+}
+
+// 有失效的數據和被重複 Put 的數據
+func TestDB_Merge2(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-merge-2")
+	opts.DirPath = dir
+	opts.DataFileSize = 32 * 1024
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	for i := 0; i < 5000; i++ {
+		err := db.Put(getTestKey(i), []byte(strings.Repeat("v", 64)))
+		assert.Nil(t, err)
+	}
+	for i := 0; i < 1000; i++ {
+		err := db.Delete(getTestKey(i))
+		assert.Nil(t, err)
+	}
+	for i := 1000; i < 2000; i++ {
+		err := db.Put(getTestKey(i), []byte(fmt.Sprintf("new-value-%d", i)))
+		assert.Nil(t, err)
+	}
+
+	sizeBefore := dirDataSize(t, dir)
+	err = db.Merge()
+	assert.Nil(t, err)
+
+	// 重啟校驗
+	err = db.Close()
+	assert.Nil(t, err)
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	assert.Less(t, dirDataSize(t, dir), sizeBefore)
+	_, err = os.Stat(db2.getMergePath())
+	assert.True(t, os.IsNotExist(err))
+	// merge 後的文件索引從 hint 文件中加載
+	_, err = os.Stat(filepath.Join(dir, data.HintFileName))
+	assert.Nil(t, err)
+
+	var keys int
+	iter := db2.NewIterator(DefaultIteratorOptions)
+	for ; iter.Valid(); iter.Next() {
+		keys++
+	}
+	iter.Close()
+	assert.Equal(t, 4000, keys)
+
+	for i := 0; i < 1000; i++ {
+		_, err := db2.Get(getTestKey(i))
+		assert.Equal(t, ErrKeyNotFound, err)
+	}
+	for i := 1000; i < 2000; i++ {
+		val, err := db2.Get(getTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte(fmt.Sprintf("new-value-%d", i)), val)
+	}
+	err = db2.Close()
+	assert.Nil(t, err)
+}
+
+// merge 的過程中有新的數據寫入或刪除
+func TestDB_Merge3(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-merge-3")
+	opts.DirPath = dir
+	opts.DataFileSize = 32 * 1024
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	for i := 0; i < 5000; i++ {
+		err := db.Put(getTestKey(i), []byte(strings.Repeat("v", 64)))
+		assert.Nil(t, err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			err := db.Delete(getTestKey(i))
+			assert.Nil(t, err)
+		}
+		for i := 5000; i < 6000; i++ {
+			err := db.Put(getTestKey(i), []byte(strings.Repeat("v", 64)))
+			assert.Nil(t, err)
+		}
+	}()
+	err = db.Merge()
+	assert.Nil(t, err)
+	<-done
+
+	err = db.Close()
+	assert.Nil(t, err)
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+
+	var keys int
+	iter := db2.NewIterator(DefaultIteratorOptions)
+	for ; iter.Valid(); iter.Next() {
+		keys++
+	}
+	iter.Close()
+	assert.Equal(t, 5000, keys)
+	_, err = db2.Get(getTestKey(10))
+	assert.Equal(t, ErrKeyNotFound, err)
+	_, err = db2.Get(getTestKey(5500))
+	assert.Nil(t, err)
+	err = db2.Close()
+	assert.Nil(t, err)
+}
+
+func dirDataSize(t *testing.T, dir string) int64 {
+	entries, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	var size int64
+	for _, entry := range entries {
+		info, err := entry.Info()
+		assert.Nil(t, err)
+		size += info.Size()
+	}
+	return size
+}
+
+// 替換數據文件的過程中失敗，merge 目錄保留下來，下次打開時繼續替換
+func TestDB_MergeResumeAfterFailedSwap(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-merge-4")
+	opts.DirPath = dir
+	opts.DataFileSize = 32 * 1024
+	db, err := Open(opts)
+	assert.Nil(t, err)
+
+	for i := 0; i < 5000; i++ {
+		assert.Nil(t, db.Put(getTestKey(i), []byte(strings.Repeat("v", 64))))
+	}
+	for i := 0; i < 1000; i++ {
+		assert.Nil(t, db.Delete(getTestKey(i)))
+	}
+	assert.Nil(t, db.Merge())
+	assert.Nil(t, db.Close())
+
+	// 用一個非空的目錄佔據 hint 文件的位置，使移動 hint 文件失敗
+	blocker := filepath.Join(dir, data.HintFileName)
+	assert.Nil(t, os.MkdirAll(filepath.Join(blocker, "blocker"), os.ModePerm))
+	_, err = Open(opts)
+	assert.NotNil(t, err)
+	_, err = os.Stat(filepath.Join(db.getMergePath(), data.HintFileName))
+	assert.Nil(t, err)
+
+	assert.Nil(t, os.RemoveAll(blocker))
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	defer destroyDB(db2)
+	_, err = os.Stat(db2.getMergePath())
+	assert.True(t, os.IsNotExist(err))
+
+	for i := 0; i < 1000; i++ {
+		_, err := db2.Get(getTestKey(i))
+		assert.Equal(t, ErrKeyNotFound, err)
+	}
+	for i := 1000; i < 5000; i++ {
+		val, err := db2.Get(getTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte(strings.Repeat("v", 64)), val)
+	}
+}
+
+// 關閉數據庫時等待正在進行的 merge 完成，merge 不會在數據文件關閉之後繼續讀取
+func TestDB_CloseDuringMerge(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-merge-close")
+	opts.DirPath = dir
+	opts.DataFileSize = 64 * 1024
+	db, err := Open(opts)
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	defer os.RemoveAll(db.getMergePath())
+	for i := 0; i < 20000; i++ {
+		assert.Nil(t, db.Put(getTestKey(i), []byte("value")))
+	}
+
+	mergeErr := make(chan error, 1)
+	go func() {
+		mergeErr <- db.Merge()
+	}()
+	for {
+		db.mu.RLock()
+		merging := db.isMerging
+		db.mu.RUnlock()
+		if merging {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	assert.Nil(t, db.Close())
+	select {
+	case err := <-mergeErr:
+		assert.Nil(t, err)
+	default:
+		t.Fatal("close returned before the merge finished")
+	}
+
+	// merge 的結果在下次打開時生效
+	db, err = Open(opts)
+	assert.Nil(t, err)
+	defer db.Close()
+	for i := 0; i < 20000; i++ {
+		val, err := db.Get(getTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("value"), val)
+	}
+}
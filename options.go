@@ -1,5 +1,11 @@
 package bitcask_go
 
+import (
+	"bitcask-go/data"
+	"os"
+	"time"
+)
+
 type Options struct {
 	// 數據庫檔數據目錄
 	DirPath string
@@ -11,7 +17,51 @@ type Options struct {
 	SyncWrites bool
 
 	// 索引類型
-	indexType IndexerType
+	IndexType IndexerType
+
+	// 啟動時是否使用 MMap 加載數據
+	MMapAtStartup bool
+
+	// 後台檢查是否需要 merge 的時間間隔，為 0 時不自動 merge
+	MergeInterval time.Duration
+
+	// 無效數據佔數據文件總大小的比例達到該閾值時，後台自動進行 merge
+	MergeRatio float32
+
+	// 寫入 value 時使用的壓縮算法，已經寫入的數據不受影響
+	Compression CompressionType
+
+	// 用於加密數據的 AES 密鑰，以密鑰 id 為索引，長度必須為 16、24 或 32 字節
+	// 輪換密鑰時需要保留舊的密鑰用於讀取，merge 時有效數據會使用當前的密鑰重新加密
+	EncryptionKeys map[uint32][]byte
+
+	// 寫入數據時使用的密鑰 id，必須大於 0，為 0 時不加密
+	// 密鑰 id 保存在每一條加密的記錄中，而不是數據文件的文件頭中，同一個文件中可以有使用不同密鑰加密的記錄
+	EncryptionKeyId uint32
+}
+
+// IteratorOptions 索引迭代器配置項
+type IteratorOptions struct {
+	// 遍歷前綴為指定值的 Key，默認為空
+	Prefix []byte
+
+	// 是否反向遍歷，默認 false 是正向
+	Reverse bool
+
+	// 遍歷範圍的起始 Key（包含），默認為空表示不限制
+	Start []byte
+
+	// 遍歷範圍的結束 Key（不包含），默認為空表示不限制
+	End []byte
+}
+
+// WriteBatchOptions 批量寫配置項
+type WriteBatchOptions struct {
+	// 一個批次當中最大的數據量
+	MaxBatchNum uint
+
+	// 提交事務時是否 sync 持久化
+	SyncWrites bool
 }
 
 type IndexerType = int8
@@ -22,4 +72,44 @@ const (
 
 	// ART 自適應基數樹索引
 	ART
+
+	// BPlusTree B+ 樹索引，將索引存儲到磁盤上
+	BPlusTree
 )
+
+// CompressionType value 的壓縮算法，和數據文件中保存的值一致
+type CompressionType = data.CompressionType
+
+const (
+	// NoCompression 不壓縮
+	NoCompression = data.NoCompression
+
+	// Snappy 壓縮和解壓速度快
+	Snappy = data.Snappy
+
+	// Zstd 壓縮率更高
+	Zstd = data.Zstd
+)
+
+var DefaultOptions = Options{
+	DirPath:       os.TempDir(),
+	DataFileSize:  256 * 1024 * 1024, // 256MB
+	SyncWrites:    false,
+	IndexType:     Btree,
+	MMapAtStartup: true,
+	MergeInterval: 0,
+	MergeRatio:    0.5,
+	Compression:   NoCompression,
+}
+
+var DefaultIteratorOptions = IteratorOptions{
+	Prefix:  nil,
+	Reverse: false,
+	Start:   nil,
+	End:     nil,
+}
+
+var DefaultWriteBatchOptions = WriteBatchOptions{
+	MaxBatchNum: 10000,
+	SyncWrites:  true,
+}
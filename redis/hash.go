@@ -0,0 +1,86 @@
+package redis
+
+import bitcask "bitcask-go"
+
+// HSet 設置 field 的值，field 之前不存在時返回 true
+func (rds *RedisDataStructure) HSet(key, field, value []byte) (bool, error) {
+	rds.mu.Lock()
+	defer rds.mu.Unlock()
+
+	meta, err := rds.findMetadata(key, Hash)
+	if err != nil {
+		return false, err
+	}
+
+	subKey := encodeSubKey(key, meta.version, field)
+	exist := true
+	if _, err = rds.db.Get(subKey); err != nil {
+		if !isNotFound(err) {
+			return false, err
+		}
+		exist = false
+	}
+
+	// 元數據和數據部分需要原子地寫入
+	wb := rds.db.NewWriteBatch(bitcask.DefaultWriteBatchOptions)
+	if !exist {
+		meta.size++
+		_ = wb.Put(encodeMetadataKey(key), meta.encode())
+	}
+	_ = wb.Put(subKey, value)
+	if err = wb.Commit(); err != nil {
+		return false, err
+	}
+	return !exist, nil
+}
+
+// HGet 獲取 field 的值，不存在時返回 bitcask.ErrKeyNotFound
+func (rds *RedisDataStructure) HGet(key, field []byte) ([]byte, error) {
+	meta, err := rds.getTyped(key, Hash)
+	if err != nil {
+		return nil, err
+	}
+	if meta == nil {
+		return nil, bitcask.ErrKeyNotFound
+	}
+	return rds.db.Get(encodeSubKey(key, meta.version, field))
+}
+
+// HDel 刪除 field，field 存在時返回 true
+func (rds *RedisDataStructure) HDel(key, field []byte) (bool, error) {
+	rds.mu.Lock()
+	defer rds.mu.Unlock()
+
+	meta, err := rds.getTyped(key, Hash)
+	if err != nil || meta == nil {
+		return false, err
+	}
+	return rds.removeSubKeys(key, meta, encodeSubKey(key, meta.version, field))
+}
+
+// removeSubKeys 刪除一個元素對應的數據部分並更新元數據，元素不存在時返回 false
+// 第一個 key 用於判斷元素是否存在，最後一個元素被刪除時同時刪除元數據
+// 在訪問此方法前必須持有互斥鎖
+func (rds *RedisDataStructure) removeSubKeys(key []byte, meta *metadata, subKeys ...[]byte) (bool, error) {
+	if _, err := rds.db.Get(subKeys[0]); err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	wb := rds.db.NewWriteBatch(bitcask.DefaultWriteBatchOptions)
+	meta.size--
+	if meta.size == 0 {
+		_ = wb.Delete(encodeMetadataKey(key))
+	} else {
+		_ = wb.Put(encodeMetadataKey(key), meta.encode())
+	}
+	for _, subKey := range subKeys {
+		_ = wb.Delete(subKey)
+	}
+	if err := wb.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
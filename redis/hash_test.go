@@ -0,0 +1,44 @@
+package redis
+
+import (
+	bitcask "bitcask-go"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRedisDataStructure_Hash(t *testing.T) {
+	rds, dir := openRds(t, "bitcask-go-redis-hash")
+	defer destroyRds(rds, dir)
+
+	ok, err := rds.HSet([]byte("h"), []byte("f1"), []byte("v1"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = rds.HSet([]byte("h"), []byte("f1"), []byte("v2"))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	ok, err = rds.HSet([]byte("h"), []byte("f2"), []byte("v3"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	val, err := rds.HGet([]byte("h"), []byte("f1"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("v2"), val)
+	_, err = rds.HGet([]byte("h"), []byte("f3"))
+	assert.Equal(t, bitcask.ErrKeyNotFound, err)
+
+	ok, err = rds.HDel([]byte("h"), []byte("f1"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = rds.HDel([]byte("h"), []byte("f1"))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	_, err = rds.HGet([]byte("h"), []byte("f1"))
+	assert.Equal(t, bitcask.ErrKeyNotFound, err)
+
+	// 刪除最後一個 field 之後整個 Hash 不再存在
+	ok, err = rds.HDel([]byte("h"), []byte("f2"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	_, err = rds.Type([]byte("h"))
+	assert.Equal(t, bitcask.ErrKeyNotFound, err)
+}
@@ -0,0 +1,87 @@
+package redis
+
+import bitcask "bitcask-go"
+
+// LPush 從頭部插入元素，返回插入之後 List 的長度
+func (rds *RedisDataStructure) LPush(key, element []byte) (uint32, error) {
+	return rds.pushInner(key, element, true)
+}
+
+// RPush 從尾部插入元素，返回插入之後 List 的長度
+func (rds *RedisDataStructure) RPush(key, element []byte) (uint32, error) {
+	return rds.pushInner(key, element, false)
+}
+
+// LPop 從頭部彈出元素，List 為空時返回 bitcask.ErrKeyNotFound
+func (rds *RedisDataStructure) LPop(key []byte) ([]byte, error) {
+	return rds.popInner(key, true)
+}
+
+// RPop 從尾部彈出元素，List 為空時返回 bitcask.ErrKeyNotFound
+func (rds *RedisDataStructure) RPop(key []byte) ([]byte, error) {
+	return rds.popInner(key, false)
+}
+
+func (rds *RedisDataStructure) pushInner(key, element []byte, isLeft bool) (uint32, error) {
+	rds.mu.Lock()
+	defer rds.mu.Unlock()
+
+	meta, err := rds.findMetadata(key, List)
+	if err != nil {
+		return 0, err
+	}
+
+	// 元素存放在 [head, tail) 的位置上
+	var index uint64
+	if isLeft {
+		meta.head--
+		index = meta.head
+	} else {
+		index = meta.tail
+		meta.tail++
+	}
+	meta.size++
+
+	wb := rds.db.NewWriteBatch(bitcask.DefaultWriteBatchOptions)
+	_ = wb.Put(encodeMetadataKey(key), meta.encode())
+	_ = wb.Put(encodeListKey(key, meta.version, index), element)
+	if err = wb.Commit(); err != nil {
+		return 0, err
+	}
+	return meta.size, nil
+}
+
+func (rds *RedisDataStructure) popInner(key []byte, isLeft bool) ([]byte, error) {
+	rds.mu.Lock()
+	defer rds.mu.Unlock()
+
+	meta, err := rds.getTyped(key, List)
+	if err != nil {
+		return nil, err
+	}
+	if meta == nil {
+		return nil, bitcask.ErrKeyNotFound
+	}
+
+	var index uint64
+	if isLeft {
+		index = meta.head
+	} else {
+		index = meta.tail - 1
+	}
+	listKey := encodeListKey(key, meta.version, index)
+	element, err := rds.db.Get(listKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if isLeft {
+		meta.head++
+	} else {
+		meta.tail--
+	}
+	if _, err = rds.removeSubKeys(key, meta, listKey); err != nil {
+		return nil, err
+	}
+	return element, nil
+}
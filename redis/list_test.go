@@ -0,0 +1,36 @@
+package redis
+
+import (
+	bitcask "bitcask-go"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRedisDataStructure_List(t *testing.T) {
+	rds, dir := openRds(t, "bitcask-go-redis-list")
+	defer destroyRds(rds, dir)
+
+	n, err := rds.LPush([]byte("l"), []byte("a"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(1), n)
+	n, err = rds.LPush([]byte("l"), []byte("b"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(2), n)
+	n, err = rds.RPush([]byte("l"), []byte("c"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(3), n)
+
+	// List 中的元素為 b a c
+	val, err := rds.LPop([]byte("l"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("b"), val)
+	val, err = rds.RPop([]byte("l"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("c"), val)
+	val, err = rds.RPop([]byte("l"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("a"), val)
+
+	_, err = rds.LPop([]byte("l"))
+	assert.Equal(t, bitcask.ErrKeyNotFound, err)
+}
@@ -0,0 +1,144 @@
+package redis
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+const (
+	maxMetadataSize   = 1 + binary.MaxVarintLen64*2 + binary.MaxVarintLen32
+	extraListMetaSize = binary.MaxVarintLen64 * 2
+
+	initialListMark = math.MaxUint64 / 2
+)
+
+// 所有的 key 都帶有前綴，用於區分元數據、數據部分以及有序集合的分數索引，避免互相衝突
+const (
+	metadataKeyPrefix byte = 'm'
+	subKeyPrefix      byte = 's'
+	scoreKeyPrefix    byte = 'z'
+)
+
+// metadata 元數據，每個數據結構對應一條
+// 數據部分的 key 中都帶有版本號，刪除元數據之後舊版本的數據部分不會再被訪問到
+type metadata struct {
+	dataType redisDataType // 數據類型
+	version  int64         // 版本號
+	size     uint32        // 數據量
+	head     uint64        // List 專用，第一個元素的位置
+	tail     uint64        // List 專用，最後一個元素的下一個位置
+}
+
+func (md *metadata) encode() []byte {
+	var size = maxMetadataSize
+	if md.dataType == List {
+		size += extraListMetaSize
+	}
+	buf := make([]byte, size)
+
+	buf[0] = md.dataType
+	var index = 1
+	index += binary.PutVarint(buf[index:], md.version)
+	index += binary.PutUvarint(buf[index:], uint64(md.size))
+
+	if md.dataType == List {
+		index += binary.PutUvarint(buf[index:], md.head)
+		index += binary.PutUvarint(buf[index:], md.tail)
+	}
+
+	return buf[:index]
+}
+
+func decodeMetadata(buf []byte) *metadata {
+	dataType := buf[0]
+
+	var index = 1
+	version, n := binary.Varint(buf[index:])
+	index += n
+	size, n := binary.Uvarint(buf[index:])
+	index += n
+
+	var head, tail uint64
+	if dataType == List {
+		head, n = binary.Uvarint(buf[index:])
+		index += n
+		tail, _ = binary.Uvarint(buf[index:])
+	}
+
+	return &metadata{
+		dataType: dataType,
+		version:  version,
+		size:     uint32(size),
+		head:     head,
+		tail:     tail,
+	}
+}
+
+// encodeMetadataKey 元數據的 key
+// prefix | key
+func encodeMetadataKey(key []byte) []byte {
+	buf := make([]byte, 1+len(key))
+	buf[0] = metadataKeyPrefix
+	copy(buf[1:], key)
+	return buf
+}
+
+// encodeSubKeyPrefix 數據部分的 key 的公共前綴
+// prefix | key size | key | version
+// key 的長度是變長的，需要帶上長度，避免一個 key 是另一個 key 的前綴時互相衝突
+func encodeSubKeyPrefix(prefix byte, key []byte, version int64) []byte {
+	buf := make([]byte, 1+binary.MaxVarintLen32+len(key)+8)
+	buf[0] = prefix
+	var index = 1
+	index += binary.PutUvarint(buf[index:], uint64(len(key)))
+	index += copy(buf[index:], key)
+	binary.BigEndian.PutUint64(buf[index:], uint64(version))
+	index += 8
+	return buf[:index]
+}
+
+// encodeSubKey 數據部分的 key，Hash 的 field、Set 以及 ZSet 的 member 都使用這種編碼
+// prefix | key size | key | version | field
+func encodeSubKey(key []byte, version int64, field []byte) []byte {
+	return append(encodeSubKeyPrefix(subKeyPrefix, key, version), field...)
+}
+
+// encodeListKey List 元素的 key
+// prefix | key size | key | version | index
+// index 使用大端序編碼，保證 key 的順序與元素的順序一致
+func encodeListKey(key []byte, version int64, index uint64) []byte {
+	buf := encodeSubKeyPrefix(subKeyPrefix, key, version)
+	return binary.BigEndian.AppendUint64(buf, index)
+}
+
+// encodeScoreKey ZSet 的分數索引 key，按照分數排序
+// prefix | key size | key | version | score | member
+func encodeScoreKey(key []byte, version int64, score float64, member []byte) []byte {
+	buf := encodeSubKeyPrefix(scoreKeyPrefix, key, version)
+	buf = append(buf, encodeScore(score)...)
+	return append(buf, member...)
+}
+
+// encodeScore 將分數編碼成字節序與數值大小順序一致的 8 個字節
+// 正數翻轉符號位，負數翻轉所有位
+func encodeScore(score float64) []byte {
+	bits := math.Float64bits(score)
+	if bits&(1<<63) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, bits)
+	return buf
+}
+
+func decodeScore(buf []byte) float64 {
+	bits := binary.BigEndian.Uint64(buf)
+	if bits&(1<<63) != 0 {
+		bits &^= 1 << 63
+	} else {
+		bits = ^bits
+	}
+	return math.Float64frombits(bits)
+}
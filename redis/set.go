@@ -0,0 +1,58 @@
+package redis
+
+import bitcask "bitcask-go"
+
+// SAdd 添加 member，member 之前不存在時返回 true
+func (rds *RedisDataStructure) SAdd(key, member []byte) (bool, error) {
+	rds.mu.Lock()
+	defer rds.mu.Unlock()
+
+	meta, err := rds.findMetadata(key, Set)
+	if err != nil {
+		return false, err
+	}
+
+	subKey := encodeSubKey(key, meta.version, member)
+	if _, err = rds.db.Get(subKey); err == nil {
+		return false, nil
+	} else if !isNotFound(err) {
+		return false, err
+	}
+
+	wb := rds.db.NewWriteBatch(bitcask.DefaultWriteBatchOptions)
+	meta.size++
+	_ = wb.Put(encodeMetadataKey(key), meta.encode())
+	_ = wb.Put(subKey, nil)
+	if err = wb.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SIsMember 判斷 member 是否存在
+func (rds *RedisDataStructure) SIsMember(key, member []byte) (bool, error) {
+	meta, err := rds.getTyped(key, Set)
+	if err != nil || meta == nil {
+		return false, err
+	}
+
+	if _, err = rds.db.Get(encodeSubKey(key, meta.version, member)); err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// SRem 刪除 member，member 存在時返回 true
+func (rds *RedisDataStructure) SRem(key, member []byte) (bool, error) {
+	rds.mu.Lock()
+	defer rds.mu.Unlock()
+
+	meta, err := rds.getTyped(key, Set)
+	if err != nil || meta == nil {
+		return false, err
+	}
+	return rds.removeSubKeys(key, meta, encodeSubKey(key, meta.version, member))
+}
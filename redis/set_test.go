@@ -0,0 +1,41 @@
+package redis
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRedisDataStructure_Set(t *testing.T) {
+	rds, dir := openRds(t, "bitcask-go-redis-set")
+	defer destroyRds(rds, dir)
+
+	ok, err := rds.SAdd([]byte("s"), []byte("m1"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = rds.SAdd([]byte("s"), []byte("m1"))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	ok, err = rds.SAdd([]byte("s"), []byte("m2"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	ok, err = rds.SIsMember([]byte("s"), []byte("m1"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = rds.SIsMember([]byte("s"), []byte("m3"))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	ok, err = rds.SIsMember([]byte("not-exist"), []byte("m1"))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	ok, err = rds.SRem([]byte("s"), []byte("m1"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = rds.SRem([]byte("s"), []byte("m1"))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	ok, err = rds.SIsMember([]byte("s"), []byte("m1"))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,172 @@
+package redis
+
+import (
+	bitcask "bitcask-go"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrWrongTypeOperation = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	ErrScoreIsNaN         = errors.New("ERR score is not a number (NaN)")
+)
+
+type redisDataType = byte
+
+const (
+	Hash redisDataType = iota + 1
+	Set
+	List
+	ZSet
+)
+
+// RedisDataStructure Redis 數據結構服務
+// 所有的數據都通過 bitcask 存儲，一個數據結構由一條元數據和若干條數據部分組成
+type RedisDataStructure struct {
+	db          *bitcask.DB
+	mu          *sync.Mutex // 寫操作需要先讀取元數據再寫入，串行執行
+	lastVersion int64
+}
+
+// NewRedisDataStructure 初始化 Redis 數據結構服務
+func NewRedisDataStructure(options bitcask.Options) (*RedisDataStructure, error) {
+	db, err := bitcask.Open(options)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisDataStructure{db: db, mu: new(sync.Mutex)}, nil
+}
+
+// Close 關閉數據庫
+func (rds *RedisDataStructure) Close() error {
+	return rds.db.Close()
+}
+
+// ======================= 通用命令 =======================
+
+// Del 刪除整個數據結構
+// 先刪除元數據使整個數據結構立即不可見，再刪除舊版本的數據部分，它們佔用的空間才能在 merge 時被回收
+func (rds *RedisDataStructure) Del(key []byte) error {
+	rds.mu.Lock()
+	defer rds.mu.Unlock()
+
+	meta, err := rds.getMetadata(key)
+	if err != nil {
+		return err
+	}
+	if err = rds.db.Delete(encodeMetadataKey(key)); err != nil {
+		return err
+	}
+	if meta == nil {
+		return nil
+	}
+	if err = rds.deleteByPrefix(encodeSubKeyPrefix(subKeyPrefix, key, meta.version)); err != nil {
+		return err
+	}
+	return rds.deleteByPrefix(encodeSubKeyPrefix(scoreKeyPrefix, key, meta.version))
+}
+
+// deleteByPrefix 刪除所有帶有指定前綴的 key，每次最多刪除一個批次的數量
+// 在訪問此方法前必須持有互斥鎖
+func (rds *RedisDataStructure) deleteByPrefix(prefix []byte) error {
+	batchOpts := bitcask.DefaultWriteBatchOptions
+	iterOpts := bitcask.DefaultIteratorOptions
+	iterOpts.Prefix = prefix
+	for {
+		var keys [][]byte
+		iter := rds.db.NewIterator(iterOpts)
+		for iter.Rewind(); iter.Valid() && uint(len(keys)) < batchOpts.MaxBatchNum; iter.Next() {
+			keys = append(keys, append([]byte(nil), iter.Key()...))
+		}
+		iter.Close()
+		if len(keys) == 0 {
+			return nil
+		}
+
+		wb := rds.db.NewWriteBatch(batchOpts)
+		for _, key := range keys {
+			_ = wb.Delete(key)
+		}
+		if err := wb.Commit(); err != nil {
+			return err
+		}
+	}
+}
+
+// Type 獲取 key 對應的數據類型，key 不存在時返回 bitcask.ErrKeyNotFound
+func (rds *RedisDataStructure) Type(key []byte) (redisDataType, error) {
+	meta, err := rds.getMetadata(key)
+	if err != nil {
+		return 0, err
+	}
+	if meta == nil {
+		return 0, bitcask.ErrKeyNotFound
+	}
+	return meta.dataType, nil
+}
+
+// getMetadata 獲取 key 對應的元數據，key 不存在時返回 nil
+func (rds *RedisDataStructure) getMetadata(key []byte) (*metadata, error) {
+	buf, err := rds.db.Get(encodeMetadataKey(key))
+	if err != nil {
+		if errors.Is(err, bitcask.ErrKeyNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return decodeMetadata(buf), nil
+}
+
+// findMetadata 查找 key 對應的元數據，key 不存在時初始化一個新的元數據
+// 在訪問此方法前必須持有互斥鎖
+func (rds *RedisDataStructure) findMetadata(key []byte, dataType redisDataType) (*metadata, error) {
+	meta, err := rds.getMetadata(key)
+	if err != nil {
+		return nil, err
+	}
+	if meta != nil {
+		if meta.dataType != dataType {
+			return nil, ErrWrongTypeOperation
+		}
+		return meta, nil
+	}
+
+	meta = &metadata{
+		dataType: dataType,
+		version:  rds.nextVersion(),
+	}
+	if dataType == List {
+		meta.head = initialListMark
+		meta.tail = initialListMark
+	}
+	return meta, nil
+}
+
+// nextVersion 生成新的版本號，保證同一個進程內嚴格遞增
+// 在訪問此方法前必須持有互斥鎖
+func (rds *RedisDataStructure) nextVersion() int64 {
+	version := time.Now().UnixNano()
+	if version <= rds.lastVersion {
+		version = rds.lastVersion + 1
+	}
+	rds.lastVersion = version
+	return version
+}
+
+// getTyped 讀取指定類型的元數據，key 不存在時返回 nil
+func (rds *RedisDataStructure) getTyped(key []byte, dataType redisDataType) (*metadata, error) {
+	meta, err := rds.getMetadata(key)
+	if err != nil {
+		return nil, err
+	}
+	if meta != nil && meta.dataType != dataType {
+		return nil, ErrWrongTypeOperation
+	}
+	return meta, nil
+}
+
+// isNotFound 判斷錯誤是否為數據不存在
+func isNotFound(err error) bool {
+	return errors.Is(err, bitcask.ErrKeyNotFound)
+}
@@ -0,0 +1,96 @@
+package redis
+
+import (
+	bitcask "bitcask-go"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+// 測試完成之後關閉並刪除數據目錄
+func destroyRds(rds *RedisDataStructure, dir string) {
+	if rds != nil {
+		_ = rds.Close()
+	}
+	_ = os.RemoveAll(dir)
+}
+
+func openRds(t *testing.T, name string) (*RedisDataStructure, string) {
+	opts := bitcask.DefaultOptions
+	dir, _ := os.MkdirTemp("", name)
+	opts.DirPath = dir
+	rds, err := NewRedisDataStructure(opts)
+	assert.Nil(t, err)
+	return rds, dir
+}
+
+func TestRedisDataStructure_DelAndType(t *testing.T) {
+	rds, dir := openRds(t, "bitcask-go-redis-del-type")
+	defer destroyRds(rds, dir)
+
+	_, err := rds.Type([]byte("k1"))
+	assert.Equal(t, bitcask.ErrKeyNotFound, err)
+
+	_, err = rds.HSet([]byte("k1"), []byte("field1"), []byte("v1"))
+	assert.Nil(t, err)
+	typ, err := rds.Type([]byte("k1"))
+	assert.Nil(t, err)
+	assert.Equal(t, Hash, typ)
+
+	// 不同類型的操作會報錯
+	_, err = rds.SAdd([]byte("k1"), []byte("member"))
+	assert.Equal(t, ErrWrongTypeOperation, err)
+
+	// 刪除之後重新創建，舊版本的數據不可見
+	err = rds.Del([]byte("k1"))
+	assert.Nil(t, err)
+	_, err = rds.HGet([]byte("k1"), []byte("field1"))
+	assert.Equal(t, bitcask.ErrKeyNotFound, err)
+
+	ok, err := rds.SAdd([]byte("k1"), []byte("member"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	typ, err = rds.Type([]byte("k1"))
+	assert.Nil(t, err)
+	assert.Equal(t, Set, typ)
+}
+
+func TestRedisDataStructure_DelSubKeys(t *testing.T) {
+	rds, dir := openRds(t, "bitcask-go-redis-del-subkeys")
+	defer destroyRds(rds, dir)
+
+	for i := 0; i < 10; i++ {
+		field := []byte(fmt.Sprintf("field-%d", i))
+		_, err := rds.HSet([]byte("hash"), field, []byte("value"))
+		assert.Nil(t, err)
+		_, err = rds.SAdd([]byte("set"), field)
+		assert.Nil(t, err)
+		_, err = rds.ZAdd([]byte("zset"), float64(i), field)
+		assert.Nil(t, err)
+		_, err = rds.RPush([]byte("list"), field)
+		assert.Nil(t, err)
+	}
+
+	// 刪除之後舊版本的數據部分也需要被刪除，否則 merge 無法回收它們佔用的空間
+	for _, key := range []string{"hash", "set", "zset", "list"} {
+		err := rds.Del([]byte(key))
+		assert.Nil(t, err)
+	}
+	stat, err := rds.db.Stat()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, stat.KeyNum)
+
+	err = rds.Del([]byte("unknown"))
+	assert.Nil(t, err)
+}
+
+func TestEncodeSubKey(t *testing.T) {
+	// 一個 key 是另一個 key 的前綴時，數據部分的 key 不能衝突
+	k1 := encodeSubKey([]byte("a"), 1, []byte("bc"))
+	k2 := encodeSubKey([]byte("ab"), 1, []byte("c"))
+	assert.NotEqual(t, k1, k2)
+
+	meta := &metadata{dataType: List, version: 100, size: 3, head: initialListMark - 1, tail: initialListMark + 2}
+	assert.Equal(t, meta, decodeMetadata(meta.encode()))
+}
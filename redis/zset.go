@@ -0,0 +1,109 @@
+package redis
+
+import (
+	bitcask "bitcask-go"
+	"math"
+)
+
+// ZAdd 添加 member 或者更新它的分數，member 之前不存在時返回 true
+// NaN 無法與其他分數比較大小，直接返回 ErrScoreIsNaN
+func (rds *RedisDataStructure) ZAdd(key []byte, score float64, member []byte) (bool, error) {
+	if math.IsNaN(score) {
+		return false, ErrScoreIsNaN
+	}
+	rds.mu.Lock()
+	defer rds.mu.Unlock()
+
+	meta, err := rds.findMetadata(key, ZSet)
+	if err != nil {
+		return false, err
+	}
+
+	// member 對應的數據部分存儲分數，分數索引用於按照分數排序
+	subKey := encodeSubKey(key, meta.version, member)
+	exist := true
+	oldScore, err := rds.db.Get(subKey)
+	if err != nil {
+		if !isNotFound(err) {
+			return false, err
+		}
+		exist = false
+	}
+	if exist && decodeScore(oldScore) == score {
+		return false, nil
+	}
+
+	wb := rds.db.NewWriteBatch(bitcask.DefaultWriteBatchOptions)
+	if exist {
+		_ = wb.Delete(encodeScoreKey(key, meta.version, decodeScore(oldScore), member))
+	} else {
+		meta.size++
+		_ = wb.Put(encodeMetadataKey(key), meta.encode())
+	}
+	_ = wb.Put(subKey, encodeScore(score))
+	_ = wb.Put(encodeScoreKey(key, meta.version, score, member), nil)
+	if err = wb.Commit(); err != nil {
+		return false, err
+	}
+	return !exist, nil
+}
+
+// ZScore 獲取 member 的分數，不存在時返回 bitcask.ErrKeyNotFound
+func (rds *RedisDataStructure) ZScore(key, member []byte) (float64, error) {
+	meta, err := rds.getTyped(key, ZSet)
+	if err != nil {
+		return 0, err
+	}
+	if meta == nil {
+		return 0, bitcask.ErrKeyNotFound
+	}
+
+	buf, err := rds.db.Get(encodeSubKey(key, meta.version, member))
+	if err != nil {
+		return 0, err
+	}
+	return decodeScore(buf), nil
+}
+
+// ZRange 按照分數從小到大返回排名在 [start, stop] 之間的 member
+// 與 Redis 一致，負數表示從後往前數的排名，-1 是最後一個
+func (rds *RedisDataStructure) ZRange(key []byte, start, stop int) ([][]byte, error) {
+	meta, err := rds.getTyped(key, ZSet)
+	if err != nil || meta == nil {
+		return nil, err
+	}
+
+	size := int(meta.size)
+	if start < 0 {
+		start += size
+	}
+	if stop < 0 {
+		stop += size
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= size {
+		stop = size - 1
+	}
+	if start > stop {
+		return nil, nil
+	}
+
+	prefix := encodeSubKeyPrefix(scoreKeyPrefix, key, meta.version)
+	iterOpts := bitcask.DefaultIteratorOptions
+	iterOpts.Prefix = prefix
+	iter := rds.db.NewIterator(iterOpts)
+	defer iter.Close()
+
+	var members [][]byte
+	rank := 0
+	for iter.Rewind(); iter.Valid() && rank <= stop; iter.Next() {
+		if rank >= start {
+			member := iter.Key()[len(prefix)+8:]
+			members = append(members, append([]byte(nil), member...))
+		}
+		rank++
+	}
+	return members, nil
+}
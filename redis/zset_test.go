@@ -0,0 +1,62 @@
+package redis
+
+import (
+	bitcask "bitcask-go"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestRedisDataStructure_ZSet(t *testing.T) {
+	rds, dir := openRds(t, "bitcask-go-redis-zset")
+	defer destroyRds(rds, dir)
+
+	ok, err := rds.ZAdd([]byte("z"), 10, []byte("m1"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = rds.ZAdd([]byte("z"), -2.5, []byte("m2"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = rds.ZAdd([]byte("z"), 3, []byte("m3"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	// 更新分數
+	ok, err = rds.ZAdd([]byte("z"), 1, []byte("m1"))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	score, err := rds.ZScore([]byte("z"), []byte("m1"))
+	assert.Nil(t, err)
+	assert.Equal(t, float64(1), score)
+	_, err = rds.ZScore([]byte("z"), []byte("m4"))
+	assert.Equal(t, bitcask.ErrKeyNotFound, err)
+
+	members, err := rds.ZRange([]byte("z"), 0, -1)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{[]byte("m2"), []byte("m1"), []byte("m3")}, members)
+	members, err = rds.ZRange([]byte("z"), 1, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{[]byte("m1")}, members)
+	members, err = rds.ZRange([]byte("z"), -2, 100)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{[]byte("m1"), []byte("m3")}, members)
+	members, err = rds.ZRange([]byte("z"), 5, 10)
+	assert.Nil(t, err)
+	assert.Nil(t, members)
+
+	// NaN 不能作為分數
+	_, err = rds.ZAdd([]byte("z"), math.NaN(), []byte("m4"))
+	assert.Equal(t, ErrScoreIsNaN, err)
+	_, err = rds.ZScore([]byte("z"), []byte("m4"))
+	assert.Equal(t, bitcask.ErrKeyNotFound, err)
+}
+
+func TestEncodeScore(t *testing.T) {
+	scores := []float64{-100.5, -1, 0, 0.25, 1, 1e10}
+	for i, score := range scores {
+		assert.Equal(t, score, decodeScore(encodeScore(score)))
+		if i > 0 {
+			assert.Less(t, string(encodeScore(scores[i-1])), string(encodeScore(score)))
+		}
+	}
+}
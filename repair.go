@@ -0,0 +1,305 @@
+package bitcask_go
+
+import (
+	"bitcask-go/data"
+	"bitcask-go/index"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/gofrs/flock"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 修復時損壞的數據會先拷貝到這個目錄中，便於事後分析
+const quarantineDirName = "quarantine"
+
+// 用於覆蓋損壞數據的刪除記錄的最小長度：CRC、類型、各佔一個字節的 key 長度、value 長度和過期時間，以及一個字節的 key
+const minFillerSize = crc32.Size + 1 + 3 + 1
+
+// CorruptRange 數據文件中無法解析出有效記錄的一段數據
+type CorruptRange struct {
+	FileId uint32
+	Offset int64
+	Size   int64
+	Torn   bool // 損壞的範圍一直延伸到文件末尾，通常是寫入到一半時崩潰導致的
+}
+
+func (r CorruptRange) String() string {
+	return fmt.Sprintf("file %d offset %d size %d torn %t", r.FileId, r.Offset, r.Size, r.Torn)
+}
+
+// Verify 校驗目錄中的所有數據文件，返回其中所有損壞的範圍
+// 只讀取數據文件，不需要打開數據庫
+func Verify(dirPath string) ([]CorruptRange, error) {
+	fileIds, err := ListDataFileIds(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	var ranges []CorruptRange
+	for _, fid := range fileIds {
+		buf, err := os.ReadFile(data.GetDataFileName(dirPath, fid))
+		if err != nil {
+			return nil, err
+		}
+
+		// 損壞的文件頭同樣視為一段損壞的數據，修復時被替換成刪除記錄後，文件按照沒有文件頭的舊版本格式讀取
+		var start int64
+		header, err := data.DecodeFileHeader(buf)
+		switch {
+		case err == data.ErrInvalidFileHeader:
+			start = min(int64(len(buf)), data.FileHeaderSize)
+			ranges = append(ranges, CorruptRange{FileId: fid, Offset: 0, Size: start, Torn: start == int64(len(buf))})
+		case err != nil:
+			return nil, err
+		default:
+			start = header.Size()
+		}
+		ranges = append(ranges, findCorruptRanges(fid, buf, start)...)
+	}
+	return ranges, nil
+}
+
+// Repair 修復目錄中損壞的數據文件，使數據庫能夠正常打開，返回修復的範圍
+// 延伸到文件末尾的損壞數據直接截斷；文件中間的損壞數據原地替換成一條無效的刪除記錄，保證其他記錄的位置不變
+// 損壞的數據會先保存到 quarantine 目錄中，hint 文件和 B+ 樹索引會被刪除，下次打開時從數據文件重建索引
+// 修復期間會持有目錄的文件鎖，數據庫不能處於打開的狀態
+func Repair(dirPath string) ([]CorruptRange, error) {
+	fileLock := flock.New(filepath.Join(dirPath, fileLockName))
+	hold, err := fileLock.TryLock()
+	if err != nil {
+		return nil, err
+	}
+	if !hold {
+		return nil, ErrDatabaseIsUsing
+	}
+	defer func() {
+		_ = fileLock.Unlock()
+	}()
+
+	ranges, err := Verify(dirPath)
+	if err != nil || len(ranges) == 0 {
+		return nil, err
+	}
+	if ranges, err = widenShortRanges(dirPath, ranges); err != nil {
+		return nil, err
+	}
+
+	for _, r := range ranges {
+		if err := quarantine(dirPath, r); err != nil {
+			return nil, err
+		}
+	}
+
+	// 修復之後索引中的位置可能已經失效，刪除之後從數據文件中重新構建
+	for _, fileName := range []string{data.HintFileName, data.MergeFinishedFileName, index.BPlusTreeIndexFileName} {
+		if err := os.Remove(filepath.Join(dirPath, fileName)); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return ranges, nil
+}
+
+// quarantine 將損壞的數據保存到 quarantine 目錄中，並從數據文件中移除
+func quarantine(dirPath string, r CorruptRange) error {
+	fileName := data.GetDataFileName(dirPath, r.FileId)
+	file, err := os.OpenFile(fileName, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	corrupted := make([]byte, r.Size)
+	if _, err := file.ReadAt(corrupted, r.Offset); err != nil {
+		return err
+	}
+	quarantineDir := filepath.Join(dirPath, quarantineDirName)
+	if err := os.MkdirAll(quarantineDir, os.ModePerm); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%09d-%d.bad", r.FileId, r.Offset)
+	if err := os.WriteFile(filepath.Join(quarantineDir, name), corrupted, 0644); err != nil {
+		return err
+	}
+
+	if r.Torn {
+		if err := file.Truncate(r.Offset); err != nil {
+			return err
+		}
+	} else {
+		filler, err := fillerRecord(r.Size)
+		if err != nil {
+			return err
+		}
+		if _, err := file.WriteAt(filler, r.Offset); err != nil {
+			return err
+		}
+	}
+	return file.Sync()
+}
+
+// widenShortRanges 擴大文件中間長度不足一條刪除記錄的損壞範圍
+// 向後合併緊接著的記錄或者下一個損壞的範圍，直到足夠寫入一條刪除記錄，被合併的記錄同樣會保存到 quarantine 目錄中
+// 一直合併到文件末尾時，整個範圍直接截斷
+func widenShortRanges(dirPath string, ranges []CorruptRange) ([]CorruptRange, error) {
+	var widened []CorruptRange
+	var buf []byte
+	bufFid := uint32(0)
+	for i := 0; i < len(ranges); i++ {
+		r := ranges[i]
+		if r.Torn || r.Size >= minFillerSize {
+			widened = append(widened, r)
+			continue
+		}
+		if buf == nil || bufFid != r.FileId {
+			var err error
+			if buf, err = os.ReadFile(data.GetDataFileName(dirPath, r.FileId)); err != nil {
+				return nil, err
+			}
+			bufFid = r.FileId
+		}
+
+		end := r.Offset + r.Size
+		for end-r.Offset < minFillerSize && !r.Torn {
+			if i+1 < len(ranges) && ranges[i+1].FileId == r.FileId && ranges[i+1].Offset == end {
+				i++
+				end += ranges[i].Size
+				r.Torn = ranges[i].Torn
+				continue
+			}
+			recordSize, ok := validRecordAt(buf, end)
+			if !ok {
+				end = int64(len(buf))
+				r.Torn = true
+				break
+			}
+			end += recordSize
+		}
+		r.Torn = r.Torn || end == int64(len(buf))
+		r.Size = end - r.Offset
+		widened = append(widened, r)
+	}
+	return widened, nil
+}
+
+// fillerRecord 構造一條長度恰好為 size 的刪除記錄，用於覆蓋損壞的數據
+// 記錄的 key 為空，加載索引時會被忽略
+func fillerRecord(size int64) ([]byte, error) {
+	key := logRecordKeyWithSeq(nil, 0, false)
+	// 過期時間編碼後的長度可以是一個或兩個字節，用來填補 value 長度變化時出現的空缺
+	for _, expiry := range []int64{0, 64} {
+		for valueLen := int64(1); valueLen <= binary.MaxVarintLen64; valueLen++ {
+			headerSize := int64(crc32.Size+1) + varintLen(int64(len(key))) + valueLen + varintLen(expiry)
+			valueSize := size - headerSize - int64(len(key))
+			if valueSize < 0 || varintLen(valueSize) != valueLen {
+				continue
+			}
+			record, _ := data.EncodeLogRecord(&data.LogRecord{
+				Key:    key,
+				Value:  make([]byte, valueSize),
+				Type:   data.LogRecordDeleted,
+				Expiry: expiry,
+			})
+			return record, nil
+		}
+	}
+	return nil, errors.New("corrupted range is too small to be filled")
+}
+
+func varintLen(x int64) int64 {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return int64(binary.PutVarint(buf, x))
+}
+
+// findCorruptRanges 從 start 處開始找出數據文件中所有損壞的範圍
+// 遇到無法解析的記錄後逐字節向後查找下一條校驗通過的記錄，兩者之間的數據都視為損壞
+func findCorruptRanges(fid uint32, buf []byte, start int64) []CorruptRange {
+	var ranges []CorruptRange
+	offset := start
+	size := int64(len(buf))
+	for offset < size {
+		if recordSize, ok := validRecordAt(buf, offset); ok {
+			offset += recordSize
+			continue
+		}
+		// 文件末尾全部是 0，說明已經沒有數據了
+		if isZero(buf[offset:]) {
+			break
+		}
+
+		next := offset + 1
+		for ; next < size; next++ {
+			if _, ok := validRecordAt(buf, next); ok {
+				break
+			}
+		}
+		ranges = append(ranges, CorruptRange{
+			FileId: fid,
+			Offset: offset,
+			Size:   next - offset,
+			Torn:   next == size,
+		})
+		offset = next
+	}
+	return ranges
+}
+
+// validRecordAt 判斷 offset 處是否是一條完整並且校驗通過的記錄，返回記錄的長度
+// 查找下一條有效記錄時每個字節都要檢查一次，先排除 header 不合法以及長度超出剩餘數據的情況，盡量避免計算 CRC
+func validRecordAt(buf []byte, offset int64) (int64, bool) {
+	header, headerSize := data.DecodeLogRecordHeader(buf[offset:])
+	if header == nil || !plausibleHeader(header) {
+		return 0, false
+	}
+	recordSize := headerSize + int64(header.KeySize()) + int64(header.ValueSize())
+	if recordSize > int64(len(buf))-offset {
+		return 0, false
+	}
+	if crc32.ChecksumIEEE(buf[offset+crc32.Size:offset+recordSize]) != header.Crc() {
+		return 0, false
+	}
+	return recordSize, true
+}
+
+// plausibleHeader 判斷 header 中的各個字段是否可能由正常的寫入產生
+// 每條記錄的 key 都至少包含一個字節的序列號，過期時間不會是負數
+func plausibleHeader(header *data.LogRecordHeader) bool {
+	return header.Type() <= data.LogRecordTxnFinished &&
+		header.Compression() <= data.Zstd &&
+		header.KeySize() > 0 &&
+		header.Expiry() >= 0
+}
+
+func isZero(buf []byte) bool {
+	for _, b := range buf {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// ListDataFileIds 獲取目錄中所有數據文件的 id，從小到大排列，文件名無法解析時返回 ErrDataDirectoryCorrupted
+func ListDataFileIds(dirPath string) ([]uint32, error) {
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	var fileIds []uint32
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), data.FileNameSuffix) {
+			continue
+		}
+		fid, err := strconv.ParseUint(strings.TrimSuffix(entry.Name(), data.FileNameSuffix), 10, 32)
+		if err != nil {
+			return nil, ErrDataDirectoryCorrupted
+		}
+		fileIds = append(fileIds, uint32(fid))
+	}
+	sort.Slice(fileIds, func(i, j int) bool { return fileIds[i] < fileIds[j] })
+	return fileIds, nil
+}
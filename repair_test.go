@@ -0,0 +1,231 @@
+package bitcask_go
+
+import (
+	"bitcask-go/data"
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestData 寫入數據並關閉數據庫，返回每條記錄在數據文件中的起始位置以及文件的末尾
+func writeTestData(t *testing.T, opts Options, n int) []int64 {
+	db, err := Open(opts)
+	assert.Nil(t, err)
+	offsets := []int64{data.FileHeaderSize}
+	for i := 0; i < n; i++ {
+		err := db.Put(getTestKey(i), []byte("value"))
+		assert.Nil(t, err)
+		offsets = append(offsets, db.activeFile.WriteOffset)
+	}
+	assert.Nil(t, db.Close())
+	return offsets
+}
+
+func TestVerifyAndRepair(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-repair")
+	opts.DirPath = dir
+	defer os.RemoveAll(dir)
+	offsets := writeTestData(t, opts, 10)
+
+	ranges, err := Verify(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(ranges))
+
+	// 損壞中間的一條記錄，並在文件末尾追加不完整的數據
+	fileName := data.GetDataFileName(dir, 0)
+	buf, err := os.ReadFile(fileName)
+	assert.Nil(t, err)
+	fileSize := int64(len(buf))
+	buf[offsets[3]+10] ^= 0xff
+	buf = append(buf, 0x01, 0x02, 0x03, 0x04, 0x00, 0x10)
+	assert.Nil(t, os.WriteFile(fileName, buf, 0644))
+
+	_, err = Open(opts)
+	assert.Equal(t, data.ErrInValidCRC, err)
+
+	ranges, err = Verify(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, []CorruptRange{
+		{FileId: 0, Offset: offsets[3], Size: offsets[4] - offsets[3]},
+		{FileId: 0, Offset: fileSize, Size: 6, Torn: true},
+	}, ranges)
+
+	repaired, err := Repair(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, ranges, repaired)
+	ranges, err = Verify(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(ranges))
+
+	// 損壞的數據被保存到 quarantine 目錄中
+	entries, err := os.ReadDir(filepath.Join(dir, quarantineDirName))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(entries))
+
+	// 修復之後可以正常打開，只有損壞的記錄丟失
+	db, err := Open(opts)
+	assert.Nil(t, err)
+	defer destroyDB(db)
+	for i := 0; i < 10; i++ {
+		val, err := db.Get(getTestKey(i))
+		if i == 3 {
+			assert.Equal(t, ErrKeyNotFound, err)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("value"), val)
+	}
+	// 新的數據寫在截斷之後的位置
+	err = db.Put(getTestKey(3), []byte("value-new"))
+	assert.Nil(t, err)
+	assert.Equal(t, fileSize, db.index.Get(getTestKey(3)).Offset)
+}
+
+func TestVerifyAndRepair_GarbageTail(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-repair-garbage")
+	opts.DirPath = dir
+	defer os.RemoveAll(dir)
+	writeTestData(t, opts, 10)
+
+	// 長度信息無效的數據不能導致校驗時崩潰
+	fileName := data.GetDataFileName(dir, 0)
+	buf, err := os.ReadFile(fileName)
+	assert.Nil(t, err)
+	fileSize := int64(len(buf))
+	buf = append(buf, bytes.Repeat([]byte{0xff}, 64)...)
+	assert.Nil(t, os.WriteFile(fileName, buf, 0644))
+
+	ranges, err := Verify(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, []CorruptRange{{FileId: 0, Offset: fileSize, Size: 64, Torn: true}}, ranges)
+
+	repaired, err := Repair(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, ranges, repaired)
+	info, err := os.Stat(fileName)
+	assert.Nil(t, err)
+	assert.Equal(t, fileSize, info.Size())
+
+	db, err := Open(opts)
+	assert.Nil(t, err)
+	defer destroyDB(db)
+	assert.Equal(t, int64(0), db.DiscardedBytes())
+	for i := 0; i < 10; i++ {
+		val, err := db.Get(getTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("value"), val)
+	}
+}
+
+func TestRepair_CorruptedFileHeader(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-repair-header")
+	opts.DirPath = dir
+	defer os.RemoveAll(dir)
+	writeTestData(t, opts, 10)
+
+	// 損壞文件頭中的創建時間
+	fileName := data.GetDataFileName(dir, 0)
+	buf, err := os.ReadFile(fileName)
+	assert.Nil(t, err)
+	buf[10] ^= 0xff
+	assert.Nil(t, os.WriteFile(fileName, buf, 0644))
+
+	_, err = Open(opts)
+	assert.Equal(t, data.ErrInvalidFileHeader, err)
+	ranges, err := Verify(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, []CorruptRange{{FileId: 0, Offset: 0, Size: data.FileHeaderSize}}, ranges)
+
+	// 修復後文件頭被替換成一條刪除記錄，文件按照舊版本的格式讀取
+	_, err = Repair(dir)
+	assert.Nil(t, err)
+	db, err := Open(opts)
+	assert.Nil(t, err)
+	defer destroyDB(db)
+	assert.Equal(t, data.LegacyFormatVersion, db.activeFile.Header.Version)
+	for i := 0; i < 10; i++ {
+		val, err := db.Get(getTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("value"), val)
+	}
+}
+
+func TestRepair_ShortRange(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-repair-short")
+	opts.DirPath = dir
+	defer os.RemoveAll(dir)
+	offsets := writeTestData(t, opts, 10)
+
+	// 在兩條記錄之間以及最後一條記錄之前插入幾個字節，長度不足以寫入一條刪除記錄
+	fileName := data.GetDataFileName(dir, 0)
+	buf, err := os.ReadFile(fileName)
+	assert.Nil(t, err)
+	garbage := []byte{0x01, 0x02, 0x03}
+	var corrupted []byte
+	corrupted = append(corrupted, buf[:offsets[4]]...)
+	corrupted = append(corrupted, garbage...)
+	corrupted = append(corrupted, buf[offsets[4]:offsets[9]]...)
+	corrupted = append(corrupted, garbage...)
+	corrupted = append(corrupted, buf[offsets[9]:]...)
+	assert.Nil(t, os.WriteFile(fileName, corrupted, 0644))
+
+	ranges, err := Verify(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, []CorruptRange{
+		{FileId: 0, Offset: offsets[4], Size: 3},
+		{FileId: 0, Offset: offsets[9] + 3, Size: 3},
+	}, ranges)
+
+	// 損壞的範圍和後面的一條記錄合併，合併到文件末尾時直接截斷
+	repaired, err := Repair(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, []CorruptRange{
+		{FileId: 0, Offset: offsets[4], Size: 3 + offsets[5] - offsets[4]},
+		{FileId: 0, Offset: offsets[9] + 3, Size: 3 + offsets[10] - offsets[9], Torn: true},
+	}, repaired)
+	ranges, err = Verify(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(ranges))
+
+	db, err := Open(opts)
+	assert.Nil(t, err)
+	defer destroyDB(db)
+	for i := 0; i < 10; i++ {
+		val, err := db.Get(getTestKey(i))
+		if i == 4 || i == 9 {
+			assert.Equal(t, ErrKeyNotFound, err)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("value"), val)
+	}
+}
+
+func TestRepair_DatabaseIsUsing(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-repair-using")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	assert.Nil(t, err)
+	defer destroyDB(db)
+
+	_, err = Repair(dir)
+	assert.Equal(t, ErrDatabaseIsUsing, err)
+}
+
+func TestFillerRecord(t *testing.T) {
+	for size := int64(minFillerSize); size < 20000; size++ {
+		record, err := fillerRecord(size)
+		assert.Nil(t, err)
+		assert.Equal(t, size, int64(len(record)))
+		recordSize, ok := validRecordAt(record, 0)
+		assert.True(t, ok)
+		assert.Equal(t, size, recordSize)
+	}
+}
@@ -0,0 +1,270 @@
+package bitcask_go
+
+import (
+	"bitcask-go/data"
+	"bitcask-go/index"
+	"bytes"
+	"github.com/google/btree"
+	"sync"
+)
+
+// Snapshot 數據庫在某一時刻的只讀視圖
+// 數據文件只會追加寫入，merge 的結果也要等到下次打開數據庫時才會替換原有文件
+// 因此只要記住創建時刻每個 key 的位置，這些位置信息在數據庫關閉之前都是有效的
+// 快照不會複製索引，寫入在修改索引之前會把 key 原來的位置記錄到尚未釋放的快照中（寫時複製）
+// 讀取時被修改過的 key 使用記錄下來的位置，其他的 key 直接讀取當前的索引
+type Snapshot struct {
+	db      *DB
+	seqNo   uint64        // 創建快照時的序列號，快照中只包含序列號不大於它的寫入
+	mu      *sync.RWMutex // 保護 changed
+	changed *btree.BTree  // 創建快照之後被修改過的 key 在創建時刻的位置，位置為空表示當時 key 不存在
+}
+
+// snapshotItem 快照中記錄的 key 以及創建快照時的位置
+type snapshotItem struct {
+	key []byte
+	pos *data.LogRecordPos
+}
+
+func (item *snapshotItem) Less(than btree.Item) bool {
+	return bytes.Compare(item.key, than.(*snapshotItem).key) < 0
+}
+
+// Snapshot 創建數據庫當前時刻的快照，使用完後需要調用 Release 釋放
+// 創建快照不需要複製索引，快照存在期間每次寫入都需要額外記錄被修改的 key，內存開銷與快照期間修改的 key 的數量成正比
+func (db *DB) Snapshot() (*Snapshot, error) {
+	// 寫入時會持有互斥鎖，加鎖後可以保證索引與序列號是一致的
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.closed {
+		return nil, ErrDatabaseClosed
+	}
+
+	snapshot := &Snapshot{
+		db:      db,
+		seqNo:   db.seqNo,
+		mu:      new(sync.RWMutex),
+		changed: btree.New(32),
+	}
+	db.snapshotMu.Lock()
+	db.snapshots[snapshot] = struct{}{}
+	db.snapshotMu.Unlock()
+	return snapshot, nil
+}
+
+// SeqNo 快照對應的序列號
+func (s *Snapshot) SeqNo() uint64 {
+	return s.seqNo
+}
+
+// Get 讀取快照中 key 對應的數據
+func (s *Snapshot) Get(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, ErrKeyIsEmpty
+	}
+
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+	if s.db.closed {
+		return nil, ErrDatabaseClosed
+	}
+	if !s.db.hasSnapshot(s) {
+		return nil, ErrSnapshotReleased
+	}
+
+	pos := s.get(key)
+	if pos == nil {
+		return nil, ErrKeyNotFound
+	}
+	return s.db.getValueByPosition(pos)
+}
+
+// NewIterator 初始化快照的迭代器，數據庫已經關閉或者快照已經釋放時返回一個無效的迭代器
+func (s *Snapshot) NewIterator(opts IteratorOptions) *Iterator {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+	if s.db.closed || !s.db.hasSnapshot(s) {
+		return s.db.newIterator(index.NewBTree().Iterator(opts.Reverse), opts)
+	}
+	return s.db.newIterator(&snapshotIterator{
+		snapshot: s,
+		live:     s.db.index.Iterator(opts.Reverse),
+		reverse:  opts.Reverse,
+	}, opts)
+}
+
+// Release 釋放快照
+func (s *Snapshot) Release() error {
+	s.db.snapshotMu.Lock()
+	defer s.db.snapshotMu.Unlock()
+	if _, ok := s.db.snapshots[s]; !ok {
+		return nil
+	}
+	delete(s.db.snapshots, s)
+	s.clear()
+	return nil
+}
+
+// get 返回 key 在創建快照時的位置，不存在時返回 nil
+// 在訪問此方法前必須持有數據庫的鎖，保證記錄的位置與當前的索引是一致的
+func (s *Snapshot) get(key []byte) *data.LogRecordPos {
+	s.mu.RLock()
+	item := s.changed.Get(&snapshotItem{key: key})
+	s.mu.RUnlock()
+	if item != nil {
+		return item.(*snapshotItem).pos
+	}
+	return s.db.index.Get(key)
+}
+
+// save 記錄 key 在被修改之前的位置，只保留第一次修改之前的位置
+func (s *Snapshot) save(key []byte, pos *data.LogRecordPos) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.changed.Has(&snapshotItem{key: key}) {
+		return
+	}
+	// 用戶傳入的 key 之後可能被修改，需要複製一份
+	s.changed.ReplaceOrInsert(&snapshotItem{key: append([]byte(nil), key...), pos: pos})
+}
+
+// nextChanged 按照遍歷的方向返回 from 之後第一個被修改過的 key，inclusive 表示是否包含 from 本身
+func (s *Snapshot) nextChanged(from []byte, inclusive bool, reverse bool) *snapshotItem {
+	var next *snapshotItem
+	iter := func(item btree.Item) bool {
+		if !inclusive && from != nil && bytes.Equal(item.(*snapshotItem).key, from) {
+			return true
+		}
+		next = item.(*snapshotItem)
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	switch {
+	case from == nil && reverse:
+		s.changed.Descend(iter)
+	case from == nil:
+		s.changed.Ascend(iter)
+	case reverse:
+		s.changed.DescendLessOrEqual(&snapshotItem{key: from}, iter)
+	default:
+		s.changed.AscendGreaterOrEqual(&snapshotItem{key: from}, iter)
+	}
+	return next
+}
+
+// clear 清空快照中記錄的數據
+func (s *Snapshot) clear() {
+	s.mu.Lock()
+	s.changed.Clear(false)
+	s.mu.Unlock()
+}
+
+// hasSnapshot 快照是否還沒有被釋放
+func (db *DB) hasSnapshot(s *Snapshot) bool {
+	db.snapshotMu.Lock()
+	defer db.snapshotMu.Unlock()
+	_, ok := db.snapshots[s]
+	return ok
+}
+
+// saveForSnapshots 在修改索引之前，將 key 當前的位置記錄到所有尚未釋放的快照中
+// 在訪問此方法前必須持有互斥鎖
+func (db *DB) saveForSnapshots(key []byte) {
+	db.snapshotMu.Lock()
+	defer db.snapshotMu.Unlock()
+	if len(db.snapshots) == 0 {
+		return
+	}
+	pos := db.index.Get(key)
+	for snapshot := range db.snapshots {
+		snapshot.save(key, pos)
+	}
+}
+
+// snapshotIterator 快照的索引迭代器
+// 合併當前索引的迭代器與快照中記錄的被修改過的 key，被修改過的 key 使用記錄下來的位置
+// 當前索引的迭代器讀取到 key 之後才檢查 key 是否被修改過，因此遍歷期間的寫入也不會影響遍歷的結果
+type snapshotIterator struct {
+	snapshot *Snapshot
+	live     index.Iterator // 當前索引的迭代器
+	reverse  bool
+	key      []byte
+	pos      *data.LogRecordPos
+	valid    bool
+}
+
+func (si *snapshotIterator) Rewind() {
+	si.live.Rewind()
+	si.moveTo(nil, true)
+}
+
+func (si *snapshotIterator) Seek(key []byte) {
+	si.live.Seek(key)
+	si.moveTo(key, true)
+}
+
+func (si *snapshotIterator) Next() {
+	if si.valid {
+		si.moveTo(si.key, false)
+	}
+}
+
+func (si *snapshotIterator) Valid() bool {
+	return si.valid
+}
+
+func (si *snapshotIterator) Key() []byte {
+	return si.key
+}
+
+func (si *snapshotIterator) Value() *data.LogRecordPos {
+	return si.pos
+}
+
+func (si *snapshotIterator) Close() {
+	si.live.Close()
+	si.valid = false
+}
+
+// moveTo 按照遍歷的方向移動到 from 之後第一個在快照中存在的 key，inclusive 表示是否包含 from 本身
+func (si *snapshotIterator) moveTo(from []byte, inclusive bool) {
+	for {
+		// 跳過當前索引中已經遍歷過的 key
+		for si.live.Valid() && !si.after(si.live.Key(), from, inclusive) {
+			si.live.Next()
+		}
+		changed := si.snapshot.nextChanged(from, inclusive, si.reverse)
+		if changed == nil && !si.live.Valid() {
+			si.valid = false
+			return
+		}
+
+		// 兩邊的 key 相同時，使用快照中記錄的位置
+		var key []byte
+		var pos *data.LogRecordPos
+		if changed != nil && (!si.live.Valid() || !si.after(changed.key, si.live.Key(), false)) {
+			key, pos = changed.key, changed.pos
+		} else {
+			key, pos = si.live.Key(), si.live.Value()
+		}
+		if pos != nil {
+			si.key, si.pos, si.valid = key, pos, true
+			return
+		}
+		// 創建快照時 key 還不存在
+		from, inclusive = key, false
+	}
+}
+
+// after 判斷按照遍歷的方向 key 是否在 from 之後，from 為空時表示沒有限制
+func (si *snapshotIterator) after(key []byte, from []byte, inclusive bool) bool {
+	if from == nil {
+		return true
+	}
+	cmp := bytes.Compare(key, from)
+	if si.reverse {
+		cmp = -cmp
+	}
+	return cmp > 0 || (inclusive && cmp == 0)
+}
@@ -0,0 +1,193 @@
+package bitcask_go
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestDB_Snapshot(t *testing.T) {
+	indexTypes := []IndexerType{Btree, ART, BPlusTree}
+	for _, typ := range indexTypes {
+		opts := DefaultOptions
+		dir, _ := os.MkdirTemp("", "bitcask-go-snapshot")
+		opts.DirPath = dir
+		opts.IndexType = typ
+		db, err := Open(opts)
+		assert.Nil(t, err)
+
+		for i := 0; i < 10; i++ {
+			err := db.Put(getTestKey(i), []byte("value-old"))
+			assert.Nil(t, err)
+		}
+
+		snapshot, err := db.Snapshot()
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(10), snapshot.SeqNo())
+
+		// 創建快照之後的寫入對快照不可見
+		err = db.Put(getTestKey(1), []byte("value-new"))
+		assert.Nil(t, err)
+		err = db.Delete(getTestKey(2))
+		assert.Nil(t, err)
+		err = db.Put(getTestKey(100), []byte("value-new"))
+		assert.Nil(t, err)
+
+		val, err := snapshot.Get(getTestKey(1))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("value-old"), val)
+		val, err = snapshot.Get(getTestKey(2))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("value-old"), val)
+		_, err = snapshot.Get(getTestKey(100))
+		assert.Equal(t, ErrKeyNotFound, err)
+
+		val, err = db.Get(getTestKey(1))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("value-new"), val)
+
+		// 快照的迭代器只能看到創建時刻的數據
+		iter := snapshot.NewIterator(DefaultIteratorOptions)
+		count := 0
+		for iter.Rewind(); iter.Valid(); iter.Next() {
+			val, err := iter.Value()
+			assert.Nil(t, err)
+			assert.Equal(t, []byte("value-old"), val)
+			count++
+		}
+		iter.Close()
+		assert.Equal(t, 10, count)
+
+		// 釋放之後不能再讀取
+		err = snapshot.Release()
+		assert.Nil(t, err)
+		_, err = snapshot.Get(getTestKey(1))
+		assert.Equal(t, ErrSnapshotReleased, err)
+
+		destroyDB(db)
+	}
+}
+
+func TestDB_SnapshotReleasedOnClose(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-snapshot-close")
+	opts.DirPath = dir
+	opts.IndexType = BPlusTree
+	db, err := Open(opts)
+	assert.Nil(t, err)
+	defer destroyDB(db)
+
+	err = db.Put(getTestKey(1), []byte("value"))
+	assert.Nil(t, err)
+	snapshot, err := db.Snapshot()
+	assert.Nil(t, err)
+
+	// 未釋放的快照在關閉數據庫時一併釋放
+	err = db.Close()
+	assert.Nil(t, err)
+	_, err = snapshot.Get(getTestKey(1))
+	assert.Equal(t, ErrDatabaseClosed, err)
+	err = snapshot.Release()
+	assert.Nil(t, err)
+}
+
+// merge 並重新打開之後序列號不會回退
+func TestDB_SnapshotSeqNoAfterMerge(t *testing.T) {
+	for _, typ := range []IndexerType{Btree, ART, BPlusTree} {
+		opts := DefaultOptions
+		dir, _ := os.MkdirTemp("", "bitcask-go-snapshot-merge")
+		opts.DirPath = dir
+		opts.IndexType = typ
+		db, err := Open(opts)
+		assert.Nil(t, err)
+
+		for i := 0; i < 100; i++ {
+			assert.Nil(t, db.Put(getTestKey(i), []byte("value")))
+		}
+		assert.Nil(t, db.Merge())
+		assert.Nil(t, db.Close())
+
+		db2, err := Open(opts)
+		assert.Nil(t, err)
+		snapshot, err := db2.Snapshot()
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(100), snapshot.SeqNo())
+		assert.Nil(t, snapshot.Release())
+
+		// 之後的寫入在 merge 的序列號基礎上繼續遞增，再次重新打開後依然有效
+		assert.Nil(t, db2.Put(getTestKey(100), []byte("value")))
+		assert.Nil(t, db2.Close())
+		db3, err := Open(opts)
+		assert.Nil(t, err)
+		snapshot, err = db3.Snapshot()
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(101), snapshot.SeqNo())
+		assert.Nil(t, snapshot.Release())
+		destroyDB(db3)
+	}
+}
+
+// 快照不複製索引，只記錄創建之後被修改過的 key，遍歷期間的寫入不影響快照的遍歷結果
+func TestDB_SnapshotCopyOnWrite(t *testing.T) {
+	for _, typ := range []IndexerType{Btree, ART, BPlusTree} {
+		opts := DefaultOptions
+		dir, _ := os.MkdirTemp("", "bitcask-go-snapshot-cow")
+		opts.DirPath = dir
+		opts.IndexType = typ
+		db, err := Open(opts)
+		assert.Nil(t, err)
+
+		for i := 0; i < 2000; i += 2 {
+			assert.Nil(t, db.Put(getTestKey(i), []byte("value-old")))
+		}
+		snapshot, err := db.Snapshot()
+		assert.Nil(t, err)
+		assert.Equal(t, 0, snapshot.changed.Len())
+
+		// 修改同一個 key 多次只記錄第一次修改之前的位置
+		assert.Nil(t, db.Put(getTestKey(0), []byte("value-new")))
+		assert.Nil(t, db.Put(getTestKey(0), []byte("value-new-2")))
+		assert.Nil(t, db.Delete(getTestKey(2)))
+		assert.Nil(t, db.Put(getTestKey(1), []byte("value-new")))
+		assert.Equal(t, 3, snapshot.changed.Len())
+
+		for _, reverse := range []bool{false, true} {
+			iter := snapshot.NewIterator(IteratorOptions{Reverse: reverse})
+			var keys [][]byte
+			for ; iter.Valid(); iter.Next() {
+				// 遍歷期間刪除還沒有遍歷到的 key，並寫入新的 key
+				if len(keys) == 10 {
+					for i := 0; i < 2000; i += 100 {
+						assert.Nil(t, db.Delete(getTestKey(i+50)))
+						assert.Nil(t, db.Put(getTestKey(i+51), []byte("value-new")))
+					}
+				}
+				val, err := iter.Value()
+				assert.Nil(t, err)
+				assert.Equal(t, []byte("value-old"), val)
+				keys = append(keys, iter.Key())
+			}
+			iter.Close()
+			assert.Equal(t, 1000, len(keys))
+			for i := 1; i < len(keys); i++ {
+				if reverse {
+					assert.True(t, bytes.Compare(keys[i-1], keys[i]) > 0)
+				} else {
+					assert.True(t, bytes.Compare(keys[i-1], keys[i]) < 0)
+				}
+			}
+		}
+
+		// Seek 同樣使用快照中的數據
+		iter := snapshot.NewIterator(DefaultIteratorOptions)
+		iter.Seek(getTestKey(1))
+		assert.Equal(t, getTestKey(2), iter.Key())
+		iter.Close()
+
+		assert.Nil(t, snapshot.Release())
+		_, err = db.Get(getTestKey(2))
+		assert.Equal(t, ErrKeyNotFound, err)
+		destroyDB(db)
+	}
+}
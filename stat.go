@@ -0,0 +1,57 @@
+package bitcask_go
+
+import (
+	"os"
+)
+
+// Stat 存儲引擎的統計信息
+type Stat struct {
+	KeyNum          int   `json:"key_num"`          // key 的總數量，包括已經過期但還沒有被 merge 或者重新打開清理掉的 key
+	DataFileNum     int   `json:"data_file_num"`    // 數據文件的數量
+	ReclaimableSize int64 `json:"reclaimable_size"` // 可以通過 merge 清理的數據量，以字節為單位
+	DiskSize        int64 `json:"disk_size"`        // 數據目錄佔據的磁盤空間大小
+}
+
+// Stat 返回數據庫的統計信息
+func (db *DB) Stat() (*Stat, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.closed {
+		return nil, ErrDatabaseClosed
+	}
+
+	dataFileNum := len(db.olderFiles)
+	if db.activeFile != nil {
+		dataFileNum++
+	}
+	diskSize, err := dirSize(db.options.DirPath)
+	if err != nil {
+		return nil, err
+	}
+	return &Stat{
+		KeyNum:          db.index.Size(),
+		DataFileNum:     dataFileNum,
+		ReclaimableSize: db.reclaimSize,
+		DiskSize:        diskSize,
+	}, nil
+}
+
+// dirSize 統計目錄中所有文件的總大小
+func dirSize(dirPath string) (int64, error) {
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return 0, err
+		}
+		size += info.Size()
+	}
+	return size, nil
+}
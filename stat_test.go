@@ -0,0 +1,153 @@
+package bitcask_go
+
+import (
+	"bitcask-go/data"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeStatTestData 寫入 100 個 key，覆蓋其中 50 個，再刪除其中 10 個
+func writeStatTestData(t *testing.T, db *DB) {
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, db.Put(getTestKey(i), []byte("value")))
+	}
+	for i := 0; i < 50; i++ {
+		assert.Nil(t, db.Put(getTestKey(i), []byte("new-value")))
+	}
+	for i := 90; i < 100; i++ {
+		assert.Nil(t, db.Delete(getTestKey(i)))
+	}
+}
+
+func TestDB_Stat(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-stat-1")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	stat, err := db.Stat()
+	assert.Nil(t, err)
+	assert.Equal(t, &Stat{}, stat)
+
+	writeStatTestData(t, db)
+
+	// 被覆蓋的 50 條記錄、被刪除的 10 條記錄以及 10 條刪除標記都是無效數據
+	var expected int64
+	for i := 0; i < 50; i++ {
+		_, size := data.EncodeLogRecord(&data.LogRecord{Key: logRecordKeyWithSeq(getTestKey(i), uint64(i+1), false), Value: []byte("value")})
+		expected += size
+	}
+	for i := 90; i < 100; i++ {
+		_, size := data.EncodeLogRecord(&data.LogRecord{Key: logRecordKeyWithSeq(getTestKey(i), uint64(i+1), false), Value: []byte("value")})
+		expected += size
+		_, size = data.EncodeLogRecord(&data.LogRecord{Key: logRecordKeyWithSeq(getTestKey(i), uint64(i+61), false), Type: data.LogRecordDeleted})
+		expected += size
+	}
+
+	stat, err = db.Stat()
+	assert.Nil(t, err)
+	assert.Equal(t, 90, stat.KeyNum)
+	assert.Equal(t, 1, stat.DataFileNum)
+	assert.Equal(t, expected, stat.ReclaimableSize)
+	assert.Equal(t, db.activeFile.WriteOffset, stat.DiskSize)
+
+	// 刪除不存在的 key 不會產生無效數據
+	assert.Nil(t, db.Delete(getTestKey(1000)))
+	stat2, err := db.Stat()
+	assert.Nil(t, err)
+	assert.Equal(t, stat.ReclaimableSize, stat2.ReclaimableSize)
+
+	// 重啟之後從數據文件中重新統計
+	assert.Nil(t, db.Close())
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	stat2, err = db2.Stat()
+	assert.Nil(t, err)
+	assert.Equal(t, stat.KeyNum, stat2.KeyNum)
+	assert.Equal(t, stat.ReclaimableSize, stat2.ReclaimableSize)
+
+	// merge 之後無效數據被清理
+	assert.Nil(t, db2.Merge())
+	assert.Nil(t, db2.Close())
+	db3, err := Open(opts)
+	assert.Nil(t, err)
+	defer destroyDB(db3)
+	stat3, err := db3.Stat()
+	assert.Nil(t, err)
+	assert.Equal(t, 90, stat3.KeyNum)
+	assert.Equal(t, int64(0), stat3.ReclaimableSize)
+
+	assert.Nil(t, db3.Close())
+	_, err = db3.Stat()
+	assert.Equal(t, ErrDatabaseClosed, err)
+}
+
+func TestDB_StatBatch(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-stat-2")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	assert.Nil(t, db.Put(getTestKey(1), []byte("value-1")))
+	wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+	assert.Nil(t, wb.Put(getTestKey(1), []byte("value-2")))
+	assert.Nil(t, wb.Put(getTestKey(2), []byte("value-2")))
+	assert.Nil(t, wb.Commit())
+
+	// 被覆蓋的記錄以及事務完成的標識都是無效數據
+	stat, err := db.Stat()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, stat.KeyNum)
+	assert.True(t, stat.ReclaimableSize > 0)
+	live := db.index.Get(getTestKey(1)).Size + db.index.Get(getTestKey(2)).Size
+	assert.Equal(t, db.activeFile.WriteOffset-data.FileHeaderSize-int64(live), stat.ReclaimableSize)
+
+	assert.Nil(t, db.Close())
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	defer destroyDB(db2)
+	stat2, err := db2.Stat()
+	assert.Nil(t, err)
+	assert.Equal(t, stat.ReclaimableSize, stat2.ReclaimableSize)
+}
+
+func TestDB_StatBPlusTree(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-stat-3")
+	opts.DirPath = dir
+	opts.IndexType = BPlusTree
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	writeStatTestData(t, db)
+	stat, err := db.Stat()
+	assert.Nil(t, err)
+	assert.Equal(t, 90, stat.KeyNum)
+	assert.True(t, stat.ReclaimableSize > 0)
+
+	// 正常關閉時可清理的字節數保存在序列號文件中
+	assert.Nil(t, db.Close())
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	stat2, err := db2.Stat()
+	assert.Nil(t, err)
+	assert.Equal(t, stat.KeyNum, stat2.KeyNum)
+	assert.Equal(t, stat.ReclaimableSize, stat2.ReclaimableSize)
+
+	// 模擬異常退出，序列號文件不存在時從數據文件中重新統計
+	assert.Nil(t, db2.Close())
+	assert.Nil(t, os.Remove(filepath.Join(dir, data.SeqNoFileName)))
+	db3, err := Open(opts)
+	assert.Nil(t, err)
+	defer destroyDB(db3)
+	stat3, err := db3.Stat()
+	assert.Nil(t, err)
+	assert.Equal(t, stat.ReclaimableSize, stat3.ReclaimableSize)
+}
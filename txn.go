@@ -0,0 +1,141 @@
+package bitcask_go
+
+import (
+	"bitcask-go/data"
+	"sync"
+)
+
+// Txn 樂觀事務
+// 事務基於開始時刻的快照讀取數據，寫入暫存在內存中，提交時檢查讀取過的 key 是否被其他寫入修改過
+// 由於數據文件只會追加寫入，key 被重新寫入後在索引中的位置一定會發生變化，因此可以直接比較索引位置來檢測衝突
+type Txn struct {
+	mu            *sync.Mutex
+	db            *DB
+	snapshot      *Snapshot                  // 事務開始時的快照
+	readKeys      map[string]struct{}        // 事務中讀取過的 key
+	pendingWrites map[string]*data.LogRecord // 暫存用戶寫入的數據
+	finished      bool                       // 事務是否已經提交或回滾
+}
+
+// Begin 開啟一個事務，使用完後需要調用 Commit 或者 Rollback
+// 每個事務都會創建一個快照，事務進行期間的寫入需要額外記錄被修改的 key，參見 Snapshot
+func (db *DB) Begin() (*Txn, error) {
+	snapshot, err := db.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &Txn{
+		mu:            new(sync.Mutex),
+		db:            db,
+		snapshot:      snapshot,
+		readKeys:      make(map[string]struct{}),
+		pendingWrites: make(map[string]*data.LogRecord),
+	}, nil
+}
+
+// Get 讀取數據，優先讀取事務中尚未提交的寫入
+func (txn *Txn) Get(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, ErrKeyIsEmpty
+	}
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+	if txn.finished {
+		return nil, ErrTxnClosed
+	}
+
+	if record := txn.pendingWrites[string(key)]; record != nil {
+		if record.Type == data.LogRecordDeleted {
+			return nil, ErrKeyNotFound
+		}
+		return record.Value, nil
+	}
+
+	// 不存在的 key 也需要記錄，提交前被其他寫入創建同樣視為衝突
+	txn.readKeys[string(key)] = struct{}{}
+	return txn.snapshot.Get(key)
+}
+
+// Put 寫入數據
+func (txn *Txn) Put(key []byte, value []byte) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+	if txn.finished {
+		return ErrTxnClosed
+	}
+
+	txn.pendingWrites[string(key)] = &data.LogRecord{Key: key, Value: value}
+	return nil
+}
+
+// Delete 刪除數據
+func (txn *Txn) Delete(key []byte) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+	if txn.finished {
+		return ErrTxnClosed
+	}
+
+	// 快照中不存在的 key 也可能在事務開始之後被其他寫入創建，因此總是寫入刪除記錄
+	txn.pendingWrites[string(key)] = &data.LogRecord{Key: key, Type: data.LogRecordDeleted}
+	return nil
+}
+
+// Commit 提交事務
+// 如果讀取過的 key 在事務開始之後被修改，則放棄寫入並返回 ErrTxnConflict
+func (txn *Txn) Commit() error {
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+	if txn.finished {
+		return ErrTxnClosed
+	}
+	txn.finished = true
+	defer txn.snapshot.Release()
+
+	if len(txn.pendingWrites) == 0 {
+		return nil
+	}
+
+	// 加鎖保證衝突檢測與寫入之間不會有其他寫入
+	txn.db.mu.Lock()
+	defer txn.db.mu.Unlock()
+	if txn.db.closed {
+		return ErrDatabaseClosed
+	}
+
+	for key := range txn.readKeys {
+		oldPos := txn.snapshot.get([]byte(key))
+		newPos := txn.db.index.Get([]byte(key))
+		if !isSamePosition(oldPos, newPos) {
+			return ErrTxnConflict
+		}
+	}
+
+	return txn.db.commitWrites(txn.pendingWrites, txn.db.options.SyncWrites)
+}
+
+// Rollback 回滾事務，丟棄所有尚未提交的寫入
+func (txn *Txn) Rollback() error {
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+	if txn.finished {
+		return nil
+	}
+	txn.finished = true
+	txn.pendingWrites = nil
+	return txn.snapshot.Release()
+}
+
+// isSamePosition 判斷兩個索引位置是否相同
+func isSamePosition(a, b *data.LogRecordPos) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Fid == b.Fid && a.Offset == b.Offset
+}
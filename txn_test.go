@@ -0,0 +1,175 @@
+package bitcask_go
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestDB_Txn(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-txn-1")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	err = db.Put(getTestKey(1), []byte("value-1"))
+	assert.Nil(t, err)
+
+	txn, err := db.Begin()
+	assert.Nil(t, err)
+	val, err := txn.Get(getTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value-1"), val)
+
+	err = txn.Put(getTestKey(2), []byte("value-2"))
+	assert.Nil(t, err)
+	err = txn.Delete(getTestKey(1))
+	assert.Nil(t, err)
+
+	// 事務內可以讀到自己的寫入，提交前對外不可見
+	val, err = txn.Get(getTestKey(2))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value-2"), val)
+	_, err = txn.Get(getTestKey(1))
+	assert.Equal(t, ErrKeyNotFound, err)
+	_, err = db.Get(getTestKey(2))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	err = txn.Commit()
+	assert.Nil(t, err)
+
+	_, err = db.Get(getTestKey(1))
+	assert.Equal(t, ErrKeyNotFound, err)
+	val, err = db.Get(getTestKey(2))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value-2"), val)
+
+	// 提交之後不能再使用
+	err = txn.Put(getTestKey(3), []byte("value-3"))
+	assert.Equal(t, ErrTxnClosed, err)
+	err = txn.Commit()
+	assert.Equal(t, ErrTxnClosed, err)
+}
+
+func TestDB_TxnConflict(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-txn-2")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	err = db.Put(getTestKey(1), []byte("100"))
+	assert.Nil(t, err)
+
+	txn1, err := db.Begin()
+	assert.Nil(t, err)
+	txn2, err := db.Begin()
+	assert.Nil(t, err)
+
+	// 兩個事務同時讀取並修改同一個 key，後提交的事務失敗
+	_, err = txn1.Get(getTestKey(1))
+	assert.Nil(t, err)
+	_, err = txn2.Get(getTestKey(1))
+	assert.Nil(t, err)
+	err = txn1.Put(getTestKey(1), []byte("101"))
+	assert.Nil(t, err)
+	err = txn2.Put(getTestKey(1), []byte("102"))
+	assert.Nil(t, err)
+
+	err = txn1.Commit()
+	assert.Nil(t, err)
+	err = txn2.Commit()
+	assert.Equal(t, ErrTxnConflict, err)
+
+	val, err := db.Get(getTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("101"), val)
+
+	// 讀取時不存在的 key 被其他寫入創建，同樣是衝突
+	txn3, err := db.Begin()
+	assert.Nil(t, err)
+	_, err = txn3.Get(getTestKey(2))
+	assert.Equal(t, ErrKeyNotFound, err)
+	err = txn3.Put(getTestKey(2), []byte("value"))
+	assert.Nil(t, err)
+	err = db.Put(getTestKey(2), []byte("other"))
+	assert.Nil(t, err)
+	err = txn3.Commit()
+	assert.Equal(t, ErrTxnConflict, err)
+
+	// 只寫入沒有讀取過的 key 不會衝突
+	txn4, err := db.Begin()
+	assert.Nil(t, err)
+	err = txn4.Put(getTestKey(1), []byte("200"))
+	assert.Nil(t, err)
+	err = db.Put(getTestKey(1), []byte("300"))
+	assert.Nil(t, err)
+	err = txn4.Commit()
+	assert.Nil(t, err)
+
+	// 回滾的事務不會寫入任何數據
+	txn5, err := db.Begin()
+	assert.Nil(t, err)
+	err = txn5.Put(getTestKey(5), []byte("value"))
+	assert.Nil(t, err)
+	err = txn5.Rollback()
+	assert.Nil(t, err)
+	_, err = db.Get(getTestKey(5))
+	assert.Equal(t, ErrKeyNotFound, err)
+}
+
+func TestDB_TxnRestart(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-txn-3")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	assert.Nil(t, err)
+
+	txn, err := db.Begin()
+	assert.Nil(t, err)
+	err = txn.Put(getTestKey(1), []byte("value-1"))
+	assert.Nil(t, err)
+	err = txn.Commit()
+	assert.Nil(t, err)
+
+	err = db.Close()
+	assert.Nil(t, err)
+	db2, err := Open(opts)
+	defer destroyDB(db2)
+	assert.Nil(t, err)
+	val, err := db2.Get(getTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value-1"), val)
+}
+
+func TestDB_TxnDeleteCreatedAfterBegin(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-txn-4")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	assert.Nil(t, err)
+
+	// 事務開始時 key 不存在，提交前被其他寫入創建，刪除仍然需要生效
+	txn, err := db.Begin()
+	assert.Nil(t, err)
+	err = db.Put(getTestKey(1), []byte("value-1"))
+	assert.Nil(t, err)
+	err = txn.Delete(getTestKey(1))
+	assert.Nil(t, err)
+	err = txn.Commit()
+	assert.Nil(t, err)
+
+	_, err = db.Get(getTestKey(1))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	err = db.Close()
+	assert.Nil(t, err)
+	db2, err := Open(opts)
+	defer destroyDB(db2)
+	assert.Nil(t, err)
+	_, err = db2.Get(getTestKey(1))
+	assert.Equal(t, ErrKeyNotFound, err)
+}